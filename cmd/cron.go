@@ -0,0 +1,138 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// cronCmd represents the cron command
+var cronCmd = &cobra.Command{
+	Use:   "cron",
+	Short: "Manage cron tasks",
+	Long: `Orchestrator cron command.
+
+Cron tasks are task templates the manager
+runs on a cron schedule, e.g. "0 3 * * *".`,
+}
+
+var cronCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a cron task",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		filename, _ := cmd.Flags().GetString("filename")
+		if !fileExists(filename) {
+			log.Fatalf("File %s does not exist\n", filename)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatalf("Cannot read file: %v\n", filename)
+		}
+		url := fmt.Sprintf("http://%s/crontasks", manager)
+		resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			e := struct{ Message string }{}
+			json.NewDecoder(resp.Body).Decode(&e)
+			log.Fatalf("Error creating cron task (%d): %s", resp.StatusCode, e.Message)
+		}
+		ct := task.CronTask{}
+		json.NewDecoder(resp.Body).Decode(&ct)
+		log.Printf("Cron task %v has been created.", ct.ID)
+	},
+}
+
+var cronListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cron tasks",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		url := fmt.Sprintf("http://%s/crontasks", manager)
+		resp, err := http.Get(url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var cronTasks []*task.CronTask
+		err = json.NewDecoder(resp.Body).Decode(&cronTasks)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "ID\tNAME\tSCHEDULE\tSUSPENDED\tACTIVE\tLAST SCHEDULE\t")
+		for _, ct := range cronTasks {
+			last := "never"
+			if !ct.LastScheduleTime.IsZero() {
+				last = fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(ct.LastScheduleTime)))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%d\t%s\t\n", ct.ID, ct.Name, ct.Schedule, ct.Suspended, len(ct.Active), last)
+		}
+		w.Flush()
+	},
+}
+
+var cronSuspendCmd = &cobra.Command{
+	Use:   "suspend <id>",
+	Short: "Suspend scheduled runs of a cron task",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		postCronTask(cmd, args[0], "suspend", http.StatusOK)
+		log.Printf("Cron task %v has been suspended.", args[0])
+	},
+}
+
+var cronResumeCmd = &cobra.Command{
+	Use:   "resume <id>",
+	Short: "Resume scheduled runs of a cron task",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		postCronTask(cmd, args[0], "resume", http.StatusOK)
+		log.Printf("Cron task %v has been resumed.", args[0])
+	},
+}
+
+var cronTriggerCmd = &cobra.Command{
+	Use:   "trigger <id>",
+	Short: "Run a cron task now",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		postCronTask(cmd, args[0], "trigger", http.StatusCreated)
+		log.Printf("Cron task %v has been triggered.", args[0])
+	},
+}
+
+func postCronTask(cmd *cobra.Command, id, action string, status int) {
+	manager, _ := cmd.Flags().GetString("manager")
+	url := fmt.Sprintf("http://%s/crontasks/%s/%s", manager, id, action)
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		log.Fatalf("Error connecting to %v: %v", manager, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		log.Fatalf("Error sending request: %v", resp.Status)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(cronCmd)
+	cronCmd.PersistentFlags().StringP("manager", "m", "localhost:5554", "Manager address")
+	cronCmd.AddCommand(cronCreateCmd, cronListCmd, cronSuspendCmd, cronResumeCmd, cronTriggerCmd)
+	cronCreateCmd.Flags().StringP("filename", "f", "crontask.json", "Cron task specification file")
+}
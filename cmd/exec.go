@@ -0,0 +1,160 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/moby/term"
+	"github.com/spf13/cobra"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec <id> -- <command> [args...]",
+	Short: "Run a command inside a running task",
+	Long: `Orchestrator exec command.
+
+The exec command runs a command inside a running task on whichever
+worker the task has been scheduled to. Without -i or -t the command
+runs to completion. Either way the exec command exits with
+the exit code of the command.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		interactive, _ := cmd.Flags().GetBool("interactive")
+		tty, _ := cmd.Flags().GetBool("tty")
+		opts := task.ExecOptions{
+			Cmd:         args[1:],
+			Tty:         tty,
+			Interactive: interactive,
+		}
+		data, err := json.Marshal(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		url := fmt.Sprintf("http://%s/tasks/%s/exec", manager, args[0])
+		if interactive || tty {
+			execInteractive(manager, url, data, tty)
+			return
+		}
+		resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			log.Fatalf("Error connecting to %v: %v", manager, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			log.Fatalf("Error running command in task %v (%s): %s", args[0], resp.Status, body)
+		}
+		result := task.ExecResult{}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprint(os.Stdout, result.Stdout)
+		fmt.Fprint(os.Stderr, result.Stderr)
+		os.Exit(result.ExitCode)
+	},
+}
+
+// execInteractive upgrades the connection to the manager
+// to a raw stream and attaches the terminal to it.
+func execInteractive(manager, url string, data []byte, tty bool) {
+	conn, err := net.Dial("tcp", manager)
+	if err != nil {
+		log.Fatalf("Error connecting to %v: %v", manager, err)
+	}
+	defer conn.Close()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	err = req.Write(conn)
+	if err != nil {
+		log.Fatalf("Error sending request: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		log.Fatalf("Error reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(resp.Body)
+		log.Fatalf("Error running command (%s): %s", resp.Status, body)
+	}
+	fd, isTerminal := term.GetFdInfo(os.Stdin)
+	var state *term.State
+	if tty && isTerminal {
+		state, err = term.SetRawTerminal(fd)
+		if err != nil {
+			log.Fatalf("Error setting terminal to raw mode: %v", err)
+		}
+	}
+	go func() {
+		io.Copy(conn, os.Stdin)
+		if c, ok := conn.(*net.TCPConn); ok {
+			c.CloseWrite()
+		}
+	}()
+	exitCode, err := readExecStream(br, os.Stdout, os.Stderr)
+	if tty && isTerminal {
+		term.RestoreTerminal(fd, state)
+	}
+	if err != nil {
+		log.Fatalf("Error reading output of the command: %v", err)
+	}
+	os.Exit(exitCode)
+}
+
+// readExecStream copies output of an interactive command
+// multiplexed by the worker with stdcopy to stdout and
+// stderr, and returns its exit code, which is sent last
+// on the stdcopy.Systemerr stream.
+func readExecStream(r io.Reader, stdout, stderr io.Writer) (int, error) {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err != nil {
+			return 0, fmt.Errorf("stream ended without an exit code: %v", err)
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(header[4:]))
+		_, err = io.ReadFull(r, frame)
+		if err != nil {
+			return 0, err
+		}
+		switch stdcopy.StdType(header[0]) {
+		case stdcopy.Stdout:
+			stdout.Write(frame)
+		case stdcopy.Stderr:
+			stderr.Write(frame)
+		case stdcopy.Systemerr:
+			return strconv.Atoi(string(frame))
+		default:
+			return 0, fmt.Errorf("unknown stream %d", header[0])
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().StringP("manager", "m", "localhost:5554", "Manager address")
+	execCmd.Flags().BoolP("interactive", "i", false, "Keep stdin of the command open")
+	execCmd.Flags().BoolP("tty", "t", false, "Allocate a pseudo-terminal")
+}
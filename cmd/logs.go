@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs [-f] <id>",
+	Short: "Print logs of a task",
+	Long: `Orchestrator logs command.
+
+The logs command prints output of a task, fetched
+through the manager from the worker the task is running on.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		follow, _ := cmd.Flags().GetBool("follow")
+		tail, _ := cmd.Flags().GetString("tail")
+		since, _ := cmd.Flags().GetString("since")
+		stdout, _ := cmd.Flags().GetBool("stdout")
+		stderr, _ := cmd.Flags().GetBool("stderr")
+		query := url.Values{}
+		query.Set("follow", fmt.Sprint(follow))
+		query.Set("tail", tail)
+		query.Set("since", since)
+		query.Set("stdout", fmt.Sprint(stdout))
+		query.Set("stderr", fmt.Sprint(stderr))
+		u := fmt.Sprintf("http://%s/tasks/%s/logs?%s", manager, args[0], query.Encode())
+		resp, err := http.Get(u)
+		if err != nil {
+			log.Fatalf("Error connecting to %v: %v", manager, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			e := struct{ Message string }{}
+			json.NewDecoder(resp.Body).Decode(&e)
+			if e.Message == "" {
+				e.Message = resp.Status
+			}
+			log.Fatalf("Error getting logs of task %v: %v", args[0], e.Message)
+		}
+		io.Copy(os.Stdout, resp.Body)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().StringP("manager", "m", "localhost:5554", "Manager address")
+	logsCmd.Flags().BoolP("follow", "f", false, "Follow log output")
+	logsCmd.Flags().String("tail", "all", "Number of lines to show from the end of the logs")
+	logsCmd.Flags().String("since", "", "Show logs since timestamp (e.g. 2024-01-02T13:23:37Z) or relative (e.g. 42m)")
+	logsCmd.Flags().Bool("stdout", true, "Show stdout of the task")
+	logsCmd.Flags().Bool("stderr", true, "Show stderr of the task")
+}
@@ -4,6 +4,7 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/vasilii314/orchestrator/manager"
 	"github.com/vasilii314/orchestrator/scheduler"
@@ -21,19 +22,40 @@ The manager controls the orchestration system and is responsible for:
 - Accepting tasks from users
 - Scheduling tasks onto worker nodes
 - Rescheduling tasks in the event of a node failure
-- Periodically polling workers to get task updates`,
+- Periodically polling workers to get task updates
+- Registering workers that join with the join token`,
 	Run: func(cmd *cobra.Command, args []string) {
 		host, _ := cmd.Flags().GetString("host")
 		port, _ := cmd.Flags().GetInt("port")
 		workers, _ := cmd.Flags().GetStringSlice("workers")
 		schedulerType, _ := cmd.Flags().GetString("scheduler")
 		storeType, _ := cmd.Flags().GetString("store")
+		portRange, _ := cmd.Flags().GetString("port-range")
+		ports, err := manager.NewPortAllocator(portRange)
+		if err != nil {
+			log.Fatal(err)
+		}
 		log.Println("Starting manager")
-		m := manager.New(workers, scheduler.SchedulerType(schedulerType), store.StoreType(storeType))
+		m := manager.New(workers, scheduler.SchedulerType(schedulerType), store.StoreType(storeType), ports)
+		m.JoinToken, _ = cmd.Flags().GetString("join-token")
+		if m.JoinToken == "" {
+			m.JoinToken, err = manager.NewJoinToken()
+			if err != nil {
+				log.Fatal(err)
+			}
+			// The token is printed once and kept out of the log
+			fmt.Printf("Join token: %s\n", m.JoinToken)
+		}
+		log.Printf("Workers can join with --join %s:%d --join-token <token>", host, port)
 		api := manager.Api{Address: host, Port: port, Manager: m}
 		go m.ProcessTasks()
+		go m.ProcessJobs()
+		go m.ProcessCronTasks()
+		go m.ProcessWorkflows()
 		go m.UpdateTasks()
 		go m.DoHealthChecks()
+		go m.ProbeTasks()
+		go m.MonitorNodes()
 		log.Printf("Starting manager API on http://%s:%d", host, port)
 		api.Start()
 	},
@@ -46,4 +68,6 @@ func init() {
 	managerCmd.Flags().StringSliceP("workers", "w", []string{"localhost:5555"}, "List of workers on which the manager will schedule the tasks")
 	managerCmd.Flags().StringP("scheduler", "s", "epvm", "Type of scheduler to use")
 	managerCmd.Flags().StringP("store", "s", "memory", "Type of datastore to use for tasks (\"memory\" or \"persistent\")")
+	managerCmd.Flags().String("join-token", "", "Token workers register with (generated if empty)")
+	managerCmd.Flags().String("port-range", "30000-32767", "Range of host ports allocated to tasks on every worker")
 }
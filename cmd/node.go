@@ -0,0 +1,268 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/vasilii314/orchestrator/node"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// nodeCmd represents the node command
+var nodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Manage worker nodes",
+	Long: `Orchestrator node command.
+
+Nodes are machines workers are running on.
+Tasks are only scheduled onto nodes that are Ready.`,
+}
+
+var nodeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List nodes and their status",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		url := fmt.Sprintf("http://%s/nodes", manager)
+		resp, err := http.Get(url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var nodes []*node.Node
+		err = json.NewDecoder(resp.Body).Decode(&nodes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tWORKER\tSTATUS\tLAST SEEN\tTASKS\tCPU\tMEMORY\tDISK\tPORTS\tCONDITIONS\tLABELS\tTAINTS\t")
+		for _, n := range nodes {
+			lastSeen := "never"
+			if !n.LastSeen.IsZero() {
+				lastSeen = fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(n.LastSeen)))
+			}
+			var conditions []string
+			for _, c := range n.Conditions {
+				if c.Active {
+					conditions = append(conditions, string(c.Type))
+				}
+			}
+			if len(conditions) == 0 {
+				conditions = append(conditions, "-")
+			}
+			status := string(n.Status)
+			if n.Unschedulable {
+				status += ",SchedulingDisabled"
+			}
+			workerName := n.WorkerName
+			if workerName == "" {
+				workerName = "-"
+			}
+			var labels []string
+			for k, v := range n.Labels {
+				labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+			}
+			sort.Strings(labels)
+			if len(labels) == 0 {
+				labels = append(labels, "-")
+			}
+			var taints []string
+			for _, t := range n.Taints {
+				taints = append(taints, t.String())
+			}
+			if len(taints) == 0 {
+				taints = append(taints, "-")
+			}
+			// Allocated resources are shown against capacity,
+			// memory is reported by nodes in kilobytes
+			cpu := fmt.Sprintf("%.2f/%d", n.CpuAllocated, n.Cores)
+			memory := fmt.Sprintf("%s/%s", units.BytesSize(float64(n.MemoryAllocated*1024)), units.BytesSize(float64(n.Memory*1024)))
+			disk := fmt.Sprintf("%s/%s", units.BytesSize(float64(n.DiskAllocated)), units.BytesSize(float64(n.Disk)))
+			var ports []string
+			for _, p := range n.PortsAllocated {
+				ports = append(ports, strconv.Itoa(p))
+			}
+			if len(ports) == 0 {
+				ports = append(ports, "-")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", n.Name, workerName, status, lastSeen, n.TaskCount, cpu, memory, disk, strings.Join(ports, ","), strings.Join(conditions, ","), strings.Join(labels, ","), strings.Join(taints, ","))
+		}
+		w.Flush()
+	},
+}
+
+var nodeRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a node, rescheduling its tasks",
+	Long: `Orchestrator node remove command.
+
+The node is removed with the join token of the manager,
+passed with --join-token.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		token, _ := cmd.Flags().GetString("join-token")
+		url := fmt.Sprintf("http://%s/nodes/%s", manager, args[0])
+		req, err := http.NewRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			log.Fatalf("Error removing node %s: %s", args[0], resp.Status)
+		}
+		log.Printf("Node %s has been removed.", args[0])
+	},
+}
+
+var nodeCordonCmd = &cobra.Command{
+	Use:   "cordon <name>",
+	Short: "Stop scheduling new tasks onto a node",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		postNode(manager, args[0], "cordon")
+		log.Printf("Node %s has been cordoned.", args[0])
+	},
+}
+
+var nodeUncordonCmd = &cobra.Command{
+	Use:   "uncordon <name>",
+	Short: "Allow scheduling new tasks onto a node again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		postNode(manager, args[0], "uncordon")
+		log.Printf("Node %s has been uncordoned.", args[0])
+	},
+}
+
+var nodeDrainCmd = &cobra.Command{
+	Use:   "drain <name>",
+	Short: "Cordon a node and move its tasks to other nodes",
+	Long: `Orchestrator node drain command.
+
+The node is cordoned and its tasks are stopped, every one
+of them within its stop grace period. Tasks are scheduled
+onto other nodes once the old instances have had their
+grace period to exit. Run uncordon once the node can take
+tasks again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		postNode(manager, args[0], "drain")
+		log.Printf("Node %s is being drained.", args[0])
+	},
+}
+
+var nodeTaintCmd = &cobra.Command{
+	Use:   "taint <name> <key>[=<value>]:<effect>",
+	Short: "Add a taint to a node",
+	Long: `Orchestrator node taint command.
+
+Tainted nodes repel tasks without a matching toleration.
+Effect is one of NoSchedule, PreferNoSchedule and NoExecute.
+NoExecute also evicts running tasks that do not tolerate it,
+which are scheduled onto other nodes once they have had
+their stop grace period to exit.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		taint, err := parseTaint(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, err := json.Marshal(taint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		url := fmt.Sprintf("http://%s/nodes/%s/taints", manager, args[0])
+		resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			e := struct{ Message string }{}
+			json.NewDecoder(resp.Body).Decode(&e)
+			log.Fatalf("Error tainting node %s (%d): %s", args[0], resp.StatusCode, e.Message)
+		}
+		log.Printf("Node %s has been tainted with %s.", args[0], taint)
+	},
+}
+
+var nodeUntaintCmd = &cobra.Command{
+	Use:   "untaint <name> <key>",
+	Short: "Remove taints with the given key from a node",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		url := fmt.Sprintf("http://%s/nodes/%s/taints/%s", manager, args[0], args[1])
+		req, err := http.NewRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("Error removing taint %s from node %s: %s", args[1], args[0], resp.Status)
+		}
+		log.Printf("Taint %s has been removed from node %s.", args[1], args[0])
+	},
+}
+
+// parseTaint parses a taint in the form of <key>[=<value>]:<effect>.
+func parseTaint(s string) (task.Taint, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return task.Taint{}, fmt.Errorf("taint %q has no effect", s)
+	}
+	taint := task.Taint{Key: s[:i], Effect: task.TaintEffect(s[i+1:])}
+	if key, value, ok := strings.Cut(taint.Key, "="); ok {
+		taint.Key = key
+		taint.Value = value
+	}
+	return taint, taint.Validate()
+}
+
+// postNode sends a request for an action on a node.
+func postNode(manager, name, action string) {
+	url := fmt.Sprintf("http://%s/nodes/%s/%s", manager, name, action)
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("Error running %s on node %s: %s", action, name, resp.Status)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(nodeCmd)
+	nodeCmd.PersistentFlags().StringP("manager", "m", "localhost:5554", "Manager address")
+	nodeCmd.AddCommand(nodeListCmd, nodeRemoveCmd, nodeCordonCmd, nodeUncordonCmd, nodeDrainCmd, nodeTaintCmd, nodeUntaintCmd)
+	nodeRemoveCmd.Flags().String("join-token", "", "Join token of the manager")
+}
@@ -40,8 +40,7 @@ The status command allows a user to get the status of tasks from the orchestrato
 			log.Fatal(err)
 		}
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
-		fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATE\tCONTAINERNAME\tIMAGE\t")
-		fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATE\tCONTAINERNAME\tIMAGE\t")
+		fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATE\tREADY\tHEALTH\tCONTAINERNAME\tIMAGE\t")
 		for _, task := range tasks {
 			var start string
 			if task.StartTime.IsZero() {
@@ -50,7 +49,11 @@ The status command allows a user to get the status of tasks from the orchestrato
 				start = fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(task.StartTime)))
 			}
 			state := task.State.String()[task.State]
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", task.ID, task.Name, start, state, task.Name, task.Image)
+			health := "-"
+			if task.Health.Status != "" {
+				health = string(task.Health.Status)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\t%s\t%s\t\n", task.ID, task.Name, start, state, task.Ready, health, task.Name, task.Image)
 		}
 		w.Flush()
 	},
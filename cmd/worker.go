@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	"github.com/vasilii314/orchestrator/store"
+	"github.com/vasilii314/orchestrator/task"
 	"github.com/vasilii314/orchestrator/worker"
 	"log"
 )
@@ -25,12 +26,53 @@ The worker runs tasks and responds to the manager's requests about task state.
 		port, _ := cmd.Flags().GetInt("port")
 		name, _ := cmd.Flags().GetString("name")
 		s, _ := cmd.Flags().GetString("store")
+		r, _ := cmd.Flags().GetString("runtime")
+		bindPaths, _ := cmd.Flags().GetStringSlice("allowed-bind-paths")
+		orphanPolicy, _ := cmd.Flags().GetString("orphan-policy")
+		if p := worker.OrphanPolicy(orphanPolicy); p != worker.OrphanReport && p != worker.OrphanRemove {
+			log.Fatalf("Unknown orphan policy %q", orphanPolicy)
+		}
 		log.Printf("Starting worker %s", name)
-		w := worker.New(name, store.StoreType(s))
+		w, err := worker.New(name, store.StoreType(s), task.RuntimeType(r))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w.AllowedBindPaths = bindPaths
+		w.OrphanPolicy = worker.OrphanPolicy(orphanPolicy)
+		w.Labels, _ = cmd.Flags().GetStringToString("labels")
+		registryAuth, _ := cmd.Flags().GetString("registry-auth")
+		if d, ok := w.Runtime.(*task.Docker); ok && registryAuth != "" {
+			auths, err := task.LoadRegistryAuths(registryAuth)
+			if err != nil {
+				log.Fatal(err)
+			}
+			d.Auths = auths
+		}
 		api := worker.Api{Address: host, Port: port, Worker: w}
 		go w.RunTasks()
 		go w.CollectStats()
 		go w.UpdateTasks()
+		go w.Reconcile()
+		address := fmt.Sprintf("%s:%d", host, port)
+		manager, _ := cmd.Flags().GetString("manager")
+		join, _ := cmd.Flags().GetString("join")
+		// The manager only accepts heartbeats
+		// presenting its join token
+		w.JoinToken, _ = cmd.Flags().GetString("join-token")
+		if (manager != "" || join != "") && w.JoinToken == "" {
+			log.Fatal("--manager and --join require --join-token")
+		}
+		if join != "" {
+			err := w.Join(join, address)
+			if err != nil {
+				log.Fatalf("Unable to join manager %s: %v", join, err)
+			}
+			log.Printf("Joined manager %s", join)
+			manager = join
+		}
+		if manager != "" {
+			go w.SendHeartbeats(manager, address)
+		}
 		log.Printf("Starting worker API on http://%s:%d", host, port)
 		api.Start()
 	},
@@ -42,4 +84,12 @@ func init() {
 	workerCmd.Flags().IntP("port", "p", 5555, "Port on which to listen")
 	workerCmd.Flags().StringP("name", "n", fmt.Sprintf("worker-%s", uuid.New().String()), "Name of the worker")
 	workerCmd.Flags().StringP("store", "s", "memory", "Type of datastore to use for tasks (\"memory\" or \"persistent\")")
+	workerCmd.Flags().StringP("runtime", "r", "docker", "Type of runtime to run tasks with (\"docker\" or \"process\")")
+	workerCmd.Flags().String("orphan-policy", "report", "What to do with containers of unknown tasks (\"report\" or \"remove\")")
+	workerCmd.Flags().String("registry-auth", "", "JSON file with credentials of private registries")
+	workerCmd.Flags().StringP("manager", "m", "", "Address of the manager to send heartbeats to (requires --join-token)")
+	workerCmd.Flags().StringToString("labels", map[string]string{}, "Labels of the node tasks can select, e.g. disk=ssd,zone=dmz")
+	workerCmd.Flags().String("join", "", "Address of the manager to register with (requires --join-token)")
+	workerCmd.Flags().String("join-token", "", "Token issued by the manager to register and send heartbeats with")
+	workerCmd.Flags().StringSlice("allowed-bind-paths", []string{}, "Host directories tasks are allowed to bind mount")
 }
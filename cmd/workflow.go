@@ -0,0 +1,120 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// workflowCmd represents the workflow command
+var workflowCmd = &cobra.Command{
+	Use:   "workflow",
+	Short: "Manage workflows",
+	Long: `Orchestrator workflow command.
+
+A workflow is a set of tasks with dependencies between
+them. A task is only run once all tasks it depends on
+have completed.`,
+}
+
+var workflowRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Submit a workflow",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		filename, _ := cmd.Flags().GetString("filename")
+		if !fileExists(filename) {
+			log.Fatalf("File %s does not exist\n", filename)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatalf("Cannot read file: %v\n", filename)
+		}
+		url := fmt.Sprintf("http://%s/workflows", manager)
+		resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			e := struct{ Message string }{}
+			json.NewDecoder(resp.Body).Decode(&e)
+			log.Fatalf("Error submitting workflow (%d): %s", resp.StatusCode, e.Message)
+		}
+		wf := task.Workflow{}
+		json.NewDecoder(resp.Body).Decode(&wf)
+		log.Printf("Workflow %v has been submitted.", wf.ID)
+	},
+}
+
+var workflowStatusCmd = &cobra.Command{
+	Use:   "status [id]",
+	Short: "Show status of workflows",
+	Long: `Orchestrator workflow status command.
+
+Without arguments the command lists all workflows.
+Given a workflow ID it shows states of its tasks.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		url := fmt.Sprintf("http://%s/workflows", manager)
+		if len(args) == 1 {
+			url = fmt.Sprintf("%s/%s", url, args[0])
+		}
+		resp, err := http.Get(url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("Error getting workflows: %v", resp.Status)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		defer w.Flush()
+		if len(args) == 0 {
+			var workflows []*task.Workflow
+			err = json.NewDecoder(resp.Body).Decode(&workflows)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Fprintln(w, "ID\tNAME\tSTATE\tTASKS\t")
+			for _, wf := range workflows {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t\n", wf.ID, wf.Name, wf.State.String()[wf.State], len(wf.Tasks))
+			}
+			return
+		}
+		wf := task.Workflow{}
+		err = json.NewDecoder(resp.Body).Decode(&wf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(w, "Workflow %s (%s): %s\n\n", wf.Name, wf.ID, wf.State.String()[wf.State])
+		fmt.Fprintln(w, "NAME\tSTATE\tDEPENDS ON\tTASK ID\tREASON\t")
+		for _, wt := range wf.Tasks {
+			taskID := "-"
+			if wt.TaskID != uuid.Nil {
+				taskID = wt.TaskID.String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", wt.Name, wt.State.String()[wt.State], strings.Join(wt.DependsOn, ","), taskID, wt.Reason)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(workflowCmd)
+	workflowCmd.PersistentFlags().StringP("manager", "m", "localhost:5554", "Manager address")
+	workflowCmd.AddCommand(workflowRunCmd, workflowStatusCmd)
+	workflowRunCmd.Flags().StringP("filename", "f", "workflow.json", "Workflow specification file")
+}
@@ -20,6 +20,37 @@ func (a *Api) initRouter() {
 		r.Get("/", a.GetTasksHandler)
 		r.Route("/{taskID}", func(r chi.Router) {
 			r.Delete("/", a.StopTasksHandler)
+			r.Get("/ports", a.GetTaskPortsHandler)
+			r.Get("/health", a.GetTaskHealthHandler)
+			r.Get("/logs", a.GetTaskLogsHandler)
+			r.Post("/exec", a.ExecTaskHandler)
+		})
+	})
+	a.Router.Route("/crontasks", func(r chi.Router) {
+		r.Post("/", a.CreateCronTaskHandler)
+		r.Get("/", a.GetCronTasksHandler)
+		r.Route("/{cronTaskID}", func(r chi.Router) {
+			r.Post("/suspend", a.SuspendCronTaskHandler)
+			r.Post("/resume", a.ResumeCronTaskHandler)
+			r.Post("/trigger", a.TriggerCronTaskHandler)
+		})
+	})
+	a.Router.Route("/workflows", func(r chi.Router) {
+		r.Post("/", a.CreateWorkflowHandler)
+		r.Get("/", a.GetWorkflowsHandler)
+		r.Get("/{workflowID}", a.GetWorkflowHandler)
+	})
+	a.Router.Route("/nodes", func(r chi.Router) {
+		r.Post("/", a.RegisterNodeHandler)
+		r.Get("/", a.GetNodesHandler)
+		r.Post("/heartbeat", a.HeartbeatHandler)
+		r.Route("/{nodeName}", func(r chi.Router) {
+			r.Delete("/", a.DeregisterNodeHandler)
+			r.Post("/cordon", a.CordonNodeHandler)
+			r.Post("/uncordon", a.UncordonNodeHandler)
+			r.Post("/drain", a.DrainNodeHandler)
+			r.Post("/taints", a.TaintNodeHandler)
+			r.Delete("/taints/{key}", a.UntaintNodeHandler)
 		})
 	})
 }
@@ -0,0 +1,316 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+const (
+	defaultSuccessfulHistoryLimit = 3
+	defaultFailedHistoryLimit     = 1
+)
+
+// AddCronTask validates and stores a cron task.
+// Its tasks are submitted by ProcessCronTasks.
+func (m *Manager) AddCronTask(ct *task.CronTask) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _, err := cronScheduleOf(ct)
+	if err != nil {
+		return err
+	}
+	switch ct.ConcurrencyPolicy {
+	case "":
+		ct.ConcurrencyPolicy = task.AllowConcurrent
+	case task.AllowConcurrent, task.ForbidConcurrent, task.ReplaceConcurrent:
+	default:
+		return fmt.Errorf("unknown concurrency policy %q", ct.ConcurrencyPolicy)
+	}
+	if ct.SuccessfulHistoryLimit == nil {
+		limit := defaultSuccessfulHistoryLimit
+		ct.SuccessfulHistoryLimit = &limit
+	}
+	if ct.FailedHistoryLimit == nil {
+		limit := defaultFailedHistoryLimit
+		ct.FailedHistoryLimit = &limit
+	}
+	if *ct.SuccessfulHistoryLimit < 0 || *ct.FailedHistoryLimit < 0 {
+		return errors.New("history limits cannot be negative")
+	}
+	if ct.ID == uuid.Nil {
+		ct.ID = uuid.New()
+	}
+	if ct.Name == "" {
+		ct.Name = ct.Task.Name
+	}
+	ct.CreationTime = time.Now().UTC()
+	ct.LastScheduleTime = time.Time{}
+	ct.LastTriggerTime = time.Time{}
+	ct.Active = nil
+	ct.Successful = nil
+	ct.Failed = nil
+	return m.CronTaskDb.Put(ct.ID.String(), ct)
+}
+
+func (m *Manager) GetCronTasks() []*task.CronTask {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listCronTasks()
+}
+
+func (m *Manager) listCronTasks() []*task.CronTask {
+	cronTasks, err := m.CronTaskDb.List()
+	if err != nil {
+		log.Printf("[manager.Manager] [GetCronTasks] Error getting list of cron tasks: %v\n", err)
+		return nil
+	}
+	return cronTasks
+}
+
+// SuspendCronTask stops or resumes scheduled runs of a cron task.
+func (m *Manager) SuspendCronTask(id string, suspended bool) (*task.CronTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ct, err := m.CronTaskDb.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	ct.Suspended = suspended
+	return ct, m.CronTaskDb.Put(ct.ID.String(), ct)
+}
+
+// TriggerCronTask submits a task of the cron task right
+// away. The concurrency policy is not applied.
+func (m *Manager) TriggerCronTask(id string) (*task.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ct, err := m.CronTaskDb.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	t := m.submitCronTask(ct, now)
+	ct.LastTriggerTime = now.UTC()
+	return t, m.CronTaskDb.Put(ct.ID.String(), ct)
+}
+
+// ProcessCronTasks periodically submits
+// tasks of cron tasks that are due.
+func (m *Manager) ProcessCronTasks() {
+	for {
+		log.Println("[manager.Manager] [ProcessCronTasks] Processing cron tasks")
+		m.runCronTasks(time.Now())
+		log.Println("[manager.Manager] [ProcessCronTasks] Sleeping for 10 seconds")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (m *Manager) runCronTasks(now time.Time) {
+	m.mu.Lock()
+	cronTasks := m.listCronTasks()
+	m.mu.Unlock()
+	for _, ct := range cronTasks {
+		m.runCronTaskOnce(ct.ID.String(), now)
+	}
+}
+
+// runCronTaskOnce updates the history of a cron task and runs
+// it if it is due. The cron task is read again under the lock,
+// so changes made through the API meanwhile are kept.
+func (m *Manager) runCronTaskOnce(id string, now time.Time) {
+	m.mu.Lock()
+	defer m.unlock()
+	ct, err := m.CronTaskDb.Get(id)
+	if err != nil {
+		return
+	}
+	m.updateCronHistory(ct)
+	if !ct.Suspended {
+		m.runCronTask(ct, now)
+	}
+	m.CronTaskDb.Put(ct.ID.String(), ct)
+}
+
+// runCronTask submits a task if a run of the cron task is due.
+// When several runs were missed, e.g. while the manager
+// was down, only the most recent one is run.
+func (m *Manager) runCronTask(ct *task.CronTask, now time.Time) {
+	schedule, loc, err := cronScheduleOf(ct)
+	if err != nil {
+		log.Printf("[manager.Manager] [runCronTask] Invalid cron task %s: %v\n", ct.ID, err)
+		return
+	}
+	last := ct.LastScheduleTime
+	if last.IsZero() {
+		last = ct.CreationTime
+	}
+	due := schedule.Next(last.In(loc))
+	if due.IsZero() || due.After(now) {
+		return
+	}
+	for next := schedule.Next(due); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		due = next
+	}
+	if len(ct.Active) > 0 {
+		switch ct.ConcurrencyPolicy {
+		case task.ForbidConcurrent:
+			log.Printf("[manager.Manager] [runCronTask] Skipping run of cron task %s at %v: previous run is still active\n", ct.ID, due)
+			ct.LastScheduleTime = due.UTC()
+			return
+		case task.ReplaceConcurrent:
+			for _, id := range ct.Active {
+				m.replaceTask(id)
+			}
+		}
+	}
+	m.submitCronTask(ct, due)
+	ct.LastScheduleTime = due.UTC()
+}
+
+// submitCronTask creates a task from the template
+// of the cron task and adds it to the Pending queue.
+// Runs are expected to finish, so they are run as jobs.
+func (m *Manager) submitCronTask(ct *task.CronTask, scheduled time.Time) *task.Task {
+	t := ct.Task
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%d", ct.Name, scheduled.Unix())
+	t.Kind = task.Job
+	t.State = task.Pending
+	t.Events = nil
+	m.Pending.Enqueue(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      t,
+	})
+	ct.Active = append(ct.Active, t.ID)
+	log.Printf("[manager.Manager] [submitCronTask] Submitted task %s of cron task %s\n", t.ID, ct.ID)
+	return &t
+}
+
+// replaceTask stops an active task of a cron
+// task to make room for a newer run.
+func (m *Manager) replaceTask(id uuid.UUID) {
+	t, err := m.TaskDb.Get(id.String())
+	if err != nil {
+		return
+	}
+	if t.IsJobParent() {
+		if t.State == task.Scheduled || t.State == task.Running {
+			m.finishJob(t, task.Completed, "Replaced")
+		}
+		return
+	}
+	w, ok := m.TaskWorkerMap[t.ID]
+	if ok && (t.State == task.Scheduled || t.State == task.Running) {
+		t.Reason = "Replaced"
+		t.Stopped = true
+		m.TaskDb.Put(t.ID.String(), t)
+		m.afterUnlock(func() {
+			m.stopTask(w, id.String())
+		})
+	}
+}
+
+// updateCronHistory moves finished tasks of the cron task
+// to its history and deletes tasks exceeding history limits.
+func (m *Manager) updateCronHistory(ct *task.CronTask) {
+	var active []uuid.UUID
+	for _, id := range ct.Active {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil {
+			// The task is still in the Pending queue
+			active = append(active, id)
+			continue
+		}
+		done, succeeded := taskFinished(t)
+		switch {
+		case !done:
+			active = append(active, id)
+		case succeeded:
+			ct.Successful = append(ct.Successful, id)
+		default:
+			ct.Failed = append(ct.Failed, id)
+		}
+	}
+	ct.Active = active
+	ct.Successful = m.trimHistory(ct.Successful, historyLimit(ct.SuccessfulHistoryLimit, defaultSuccessfulHistoryLimit))
+	ct.Failed = m.trimHistory(ct.Failed, historyLimit(ct.FailedHistoryLimit, defaultFailedHistoryLimit))
+}
+
+// historyLimit returns the history limit,
+// or the default one if it is not set.
+func historyLimit(limit *int, def int) int {
+	if limit == nil {
+		return def
+	}
+	return *limit
+}
+
+func (m *Manager) trimHistory(history []uuid.UUID, limit int) []uuid.UUID {
+	for len(history) > limit {
+		m.deleteTask(history[0])
+		history = history[1:]
+	}
+	return history
+}
+
+// deleteTask removes a finished task, and
+// instances if it is a job, from the manager.
+func (m *Manager) deleteTask(id uuid.UUID) {
+	for _, t := range m.listTasks() {
+		if t.ID == id || t.JobID == id {
+			if w, ok := m.TaskWorkerMap[t.ID]; ok {
+				m.Ports.Release(w, t.ID)
+				delete(m.TaskWorkerMap, t.ID)
+				m.WorkerTaskMap[w] = removeTaskID(m.WorkerTaskMap[w], t.ID)
+			}
+			m.TaskDb.Delete(t.ID.String())
+		}
+	}
+	log.Printf("[manager.Manager] [deleteTask] Deleted task %s\n", id)
+}
+
+func removeTaskID(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+	var result []uuid.UUID
+	for _, i := range ids {
+		if i != id {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// taskFinished checks whether the task will not be run
+// again and whether it has finished successfully.
+func taskFinished(t *task.Task) (bool, bool) {
+	switch {
+	case t.State == task.Completed:
+		return !needsRestart(t) || !t.CanRestart(), true
+	case t.State != task.Failed:
+		return false, false
+	case t.IsJobParent():
+		return true, false
+	case t.Kind == task.Job:
+		return t.RestartCount >= t.BackoffLimit, false
+	default:
+		return !needsRestart(t) || !t.CanRestart(), false
+	}
+}
+
+func cronScheduleOf(ct *task.CronTask) (*cronSchedule, *time.Location, error) {
+	schedule, err := parseCron(ct.Schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := time.LoadLocation(ct.TimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid time zone %q: %v", ct.TimeZone, err)
+	}
+	return schedule, loc, nil
+}
@@ -0,0 +1,31 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vasilii314/orchestrator/task"
+)
+
+func TestCronRunExitsWithZero(t *testing.T) {
+	m := newTestManager(t, testWorker)
+	ct := &task.CronTask{Name: "backup", Schedule: "@hourly"}
+	err := m.AddCronTask(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.TriggerCronTask(ct.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := placePending(t, m, testWorker)
+	if tk.Kind != task.Job {
+		t.Fatalf("run is submitted as %q, want %q", tk.Kind, task.Job)
+	}
+	report(m, testWorker, tk.ID, task.Completed, 0)
+	m.runCronTaskOnce(ct.ID.String(), time.Now())
+	ct, _ = m.CronTaskDb.Get(ct.ID.String())
+	if len(ct.Active) != 0 || len(ct.Failed) != 0 || len(ct.Successful) != 1 || ct.Successful[0] != tk.ID {
+		t.Errorf("active %v, successful %v, failed %v, want run %s to have succeeded", ct.Active, ct.Successful, ct.Failed, tk.ID)
+	}
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// maxMissedPolls is the number of consecutive polls
+// a worker can miss before it is considered down.
+const maxMissedPolls = 3
+
+func (m *Manager) isWorkerDown(worker string) bool {
+	return m.MissedPolls[worker] >= maxMissedPolls
+}
+
+// missPoll records a failed poll of the worker.
+// Tasks of a worker that has just been
+// considered down are rescheduled.
+func (m *Manager) missPoll(worker string) {
+	m.MissedPolls[worker]++
+	if m.MissedPolls[worker] == maxMissedPolls {
+		log.Printf("[manager.Manager] [missPoll] Worker %s has missed %d polls and is considered down\n", worker, maxMissedPolls)
+		m.rescheduleTasks(worker, fmt.Sprintf("Worker %s is unreachable", worker))
+	}
+}
+
+// rescheduleTasks marks tasks assigned to the worker
+// as lost and submits them again, so the scheduler
+// places them on workers that are up.
+func (m *Manager) rescheduleTasks(worker, reason string) {
+	for _, id := range m.WorkerTaskMap[worker] {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil {
+			continue
+		}
+		delete(m.TaskWorkerMap, id)
+		m.Ports.Release(worker, id)
+		if !isActive(t) {
+			t.Worker = ""
+			m.TaskDb.Put(t.ID.String(), t)
+			continue
+		}
+		m.resubmitTask(t, task.Lost, "Lost", reason)
+		log.Printf("[manager.Manager] [rescheduleTasks] Task %s on worker %s is lost and will be rescheduled\n", t.ID, worker)
+	}
+	m.WorkerTaskMap[worker] = []uuid.UUID{}
+}
+
+// resubmitTask moves a task taken off its worker to the
+// given state and submits it to the scheduler again.
+func (m *Manager) resubmitTask(t *task.Task, state task.State, event, reason string) {
+	t.State = state
+	t.Worker = ""
+	t.Ready = false
+	t.Settled = false
+	t.Health.Reset()
+	t.Readiness.Reset()
+	t.Reason = reason
+	t.AddEvents(task.NewEvent(event, reason))
+	m.TaskDb.Put(t.ID.String(), t)
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      *t,
+	}
+	te.Task.State = task.Scheduled
+	m.Pending.Enqueue(te)
+}
+
+// willRestart checks whether a task that is not
+// running is waiting to be restarted.
+func willRestart(t *task.Task) bool {
+	if t.Kind == task.Job {
+		return t.State == task.Failed && t.RestartCount < t.BackoffLimit
+	}
+	return needsRestart(t) && t.CanRestart()
+}
+
+// fenceTask stops an instance of a task reported by a
+// worker the task is no longer assigned to, e.g. when
+// a worker that has been down comes back.
+func (m *Manager) fenceTask(worker string, t *task.Task) {
+	if t.State != task.Scheduled && t.State != task.Running {
+		return
+	}
+	log.Printf("[manager.Manager] [fenceTask] Stopping duplicate instance of task %s on worker %s\n", t.ID, worker)
+	id := t.ID.String()
+	m.afterUnlock(func() {
+		m.stopTask(worker, id)
+	})
+}
@@ -2,12 +2,20 @@ package manager
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/node"
 	"github.com/vasilii314/orchestrator/task"
+	"github.com/vasilii314/orchestrator/utils"
+	"github.com/vasilii314/orchestrator/worker"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -32,6 +40,17 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(e)
 		return
 	}
+	err = taskEvent.Task.ValidatePlacement()
+	if err != nil {
+		msg := fmt.Sprintf("Invalid task %v: %v\n", taskEvent.Task.ID, err)
+		w.WriteHeader(http.StatusBadRequest)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
 	a.Manager.AddTask(taskEvent)
 	log.Printf("[manager.Api] [StartTaskHandler] Added task %v\n", taskEvent.Task.ID)
 	w.WriteHeader(http.StatusCreated)
@@ -52,7 +71,7 @@ func (a *Api) StopTasksHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	tID, _ := uuid.Parse(taskID)
-	taskToStop, err := a.Manager.TaskDb.Get(tID.String())
+	taskToStop, err := a.Manager.GetTask(tID.String())
 	if err != nil {
 		log.Printf("[manager.Api] [StopTasksHandler] No task with ID %v found\n", tID)
 		w.WriteHeader(http.StatusNotFound)
@@ -70,3 +89,390 @@ func (a *Api) StopTasksHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[manager.Api] [StopTasksHandler] Added task event %v to stop task %v\n", taskEvent.ID, taskToStop.ID)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetTaskPortsHandler returns addresses (<host>:<port>) clients
+// can reach the task on, keyed by both port names and container ports.
+// Tasks that are not ready get 503 Service Unavailable instead.
+func (a *Api) GetTaskPortsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	tID, _ := uuid.Parse(taskID)
+	t, err := a.Manager.GetTask(tID.String())
+	if err != nil {
+		log.Printf("[manager.Api] [GetTaskPortsHandler] No task with ID %v found\n", tID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !t.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusServiceUnavailable,
+			Message:        fmt.Sprintf("task %v is not ready", tID),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	taskWorker, _ := a.Manager.TaskWorker(t.ID)
+	host := strings.Split(taskWorker, ":")[0]
+	ports := make(map[string]string)
+	for port := range t.Bindings() {
+		if hostPort, ok := t.HostPort(port); ok {
+			ports[port] = fmt.Sprintf("%s:%s", host, hostPort)
+		}
+	}
+	for name := range t.NamedPorts {
+		if hostPort, ok := t.HostPort(name); ok {
+			ports[name] = fmt.Sprintf("%s:%s", host, hostPort)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ports)
+}
+
+// GetTaskHealthHandler returns the health
+// status and check history of a task.
+func (a *Api) GetTaskHealthHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	tID, _ := uuid.Parse(taskID)
+	t, err := a.Manager.GetTask(tID.String())
+	if err != nil {
+		log.Printf("[manager.Api] [GetTaskHealthHandler] No task with ID %v found\n", tID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(t.Health)
+}
+
+// GetTaskLogsHandler proxies a request for task
+// logs to the worker the task is running on.
+func (a *Api) GetTaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	tID, _ := uuid.Parse(taskID)
+	taskWorker, ok := a.Manager.TaskWorker(tID)
+	if !ok {
+		log.Printf("[manager.Api] [GetTaskLogsHandler] No task with ID %v found\n", tID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	url := fmt.Sprintf("http://%s/tasks/%s/logs?%s", taskWorker, tID, r.URL.RawQuery)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		log.Printf("[manager.Api] [GetTaskLogsHandler] Error creating request %s: %v\n", url, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("[manager.Api] [GetTaskLogsHandler] Error connecting to worker %s: %v\n", taskWorker, err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(utils.FlushWriter{W: w}, resp.Body)
+}
+
+// ExecTaskHandler proxies exec requests to the worker the task
+// is running on. Upgraded (interactive) connections are
+// proxied as raw streams by httputil.ReverseProxy.
+func (a *Api) ExecTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	tID, _ := uuid.Parse(taskID)
+	taskWorker, ok := a.Manager.TaskWorker(tID)
+	if !ok {
+		log.Printf("[manager.Api] [ExecTaskHandler] No task with ID %v found\n", tID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: taskWorker})
+	proxy.ServeHTTP(w, r)
+}
+
+func (a *Api) CreateCronTaskHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	ct := task.CronTask{}
+	err := d.Decode(&ct)
+	if err == nil {
+		err = a.Manager.AddCronTask(&ct)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error creating cron task: %v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	log.Printf("[manager.Api] [CreateCronTaskHandler] Added cron task %v\n", ct.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ct)
+}
+
+func (a *Api) GetCronTasksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a.Manager.GetCronTasks())
+}
+
+func (a *Api) SuspendCronTaskHandler(w http.ResponseWriter, r *http.Request) {
+	a.setCronTaskSuspended(w, r, true)
+}
+
+func (a *Api) ResumeCronTaskHandler(w http.ResponseWriter, r *http.Request) {
+	a.setCronTaskSuspended(w, r, false)
+}
+
+func (a *Api) setCronTaskSuspended(w http.ResponseWriter, r *http.Request, suspended bool) {
+	cronTaskID := chi.URLParam(r, "cronTaskID")
+	ctID, _ := uuid.Parse(cronTaskID)
+	ct, err := a.Manager.SuspendCronTask(ctID.String(), suspended)
+	if err != nil {
+		log.Printf("[manager.Api] [setCronTaskSuspended] No cron task with ID %v found\n", ctID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ct)
+}
+
+// TriggerCronTaskHandler submits a task of the cron task
+// immediately, regardless of its schedule.
+func (a *Api) TriggerCronTaskHandler(w http.ResponseWriter, r *http.Request) {
+	cronTaskID := chi.URLParam(r, "cronTaskID")
+	ctID, _ := uuid.Parse(cronTaskID)
+	t, err := a.Manager.TriggerCronTask(ctID.String())
+	if err != nil {
+		log.Printf("[manager.Api] [TriggerCronTaskHandler] No cron task with ID %v found\n", ctID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Printf("[manager.Api] [TriggerCronTaskHandler] Triggered cron task %v\n", ctID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+func (a *Api) CreateWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	wf := task.Workflow{}
+	err := d.Decode(&wf)
+	if err == nil {
+		err = a.Manager.AddWorkflow(&wf)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error creating workflow: %v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	log.Printf("[manager.Api] [CreateWorkflowHandler] Added workflow %v\n", wf.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wf)
+}
+
+func (a *Api) GetWorkflowsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a.Manager.GetWorkflows())
+}
+
+func (a *Api) GetWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	workflowID := chi.URLParam(r, "workflowID")
+	wID, _ := uuid.Parse(workflowID)
+	wf, err := a.Manager.GetWorkflow(wID.String())
+	if err != nil {
+		log.Printf("[manager.Api] [GetWorkflowHandler] No workflow with ID %v found\n", wID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wf)
+}
+
+// authorized checks that the request presents the manager's
+// join token and responds with 401 Unauthorized if it does not.
+func (a *Api) authorized(w http.ResponseWriter, r *http.Request, handler string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if a.Manager.ValidJoinToken(token) {
+		return true
+	}
+	log.Printf("[manager.Api] [%s] Invalid join token\n", handler)
+	w.WriteHeader(http.StatusUnauthorized)
+	e := ErrResponse{
+		HTTPStatusCode: http.StatusUnauthorized,
+		Message:        "invalid join token",
+	}
+	json.NewEncoder(w).Encode(e)
+	return false
+}
+
+// RegisterNodeHandler adds the node of a worker
+// that presents the manager's join token.
+func (a *Api) RegisterNodeHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r, "RegisterNodeHandler") {
+		return
+	}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	reg := worker.Registration{}
+	err := d.Decode(&reg)
+	var n *node.Node
+	if err == nil {
+		n, err = a.Manager.RegisterNode(reg)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error registering worker: %v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	log.Printf("[manager.Api] [RegisterNodeHandler] Registered node %s\n", n.Name)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(n)
+}
+
+// DeregisterNodeHandler removes the node of a worker.
+// The request has to present the manager's join token.
+func (a *Api) DeregisterNodeHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r, "DeregisterNodeHandler") {
+		return
+	}
+	nodeName := chi.URLParam(r, "nodeName")
+	err := a.Manager.DeregisterNode(nodeName)
+	if errors.Is(err, ErrUnknownNode) {
+		log.Printf("[manager.Api] [DeregisterNodeHandler] No node %s found\n", nodeName)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[manager.Api] [DeregisterNodeHandler] Error removing node %s: %v\n", nodeName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) CordonNodeHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeNode(w, r, "CordonNodeHandler", func(name string) (*node.Node, error) {
+		return a.Manager.CordonNode(name, true)
+	})
+}
+
+func (a *Api) UncordonNodeHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeNode(w, r, "UncordonNodeHandler", func(name string) (*node.Node, error) {
+		return a.Manager.CordonNode(name, false)
+	})
+}
+
+// DrainNodeHandler cordons a node and
+// moves its tasks to other nodes.
+func (a *Api) DrainNodeHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeNode(w, r, "DrainNodeHandler", a.Manager.DrainNode)
+}
+
+// TaintNodeHandler adds the taint in the request body to a node.
+func (a *Api) TaintNodeHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	taint := task.Taint{}
+	err := d.Decode(&taint)
+	if err == nil {
+		err = taint.Validate()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error adding taint: %v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	a.writeNode(w, r, "TaintNodeHandler", func(name string) (*node.Node, error) {
+		return a.Manager.TaintNode(name, taint)
+	})
+}
+
+func (a *Api) UntaintNodeHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	a.writeNode(w, r, "UntaintNodeHandler", func(name string) (*node.Node, error) {
+		return a.Manager.UntaintNode(name, key)
+	})
+}
+
+// writeNode applies f to the node named in the
+// request and responds with the updated node.
+func (a *Api) writeNode(w http.ResponseWriter, r *http.Request, handler string, f func(string) (*node.Node, error)) {
+	nodeName := chi.URLParam(r, "nodeName")
+	n, err := f(nodeName)
+	if errors.Is(err, ErrUnknownNode) {
+		log.Printf("[manager.Api] [%s] No node %s found\n", handler, nodeName)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[manager.Api] [%s] Error updating node %s: %v\n", handler, nodeName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(n)
+}
+
+func (a *Api) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a.Manager.GetNodes())
+}
+
+// HeartbeatHandler records a heartbeat sent by a
+// worker that presents the manager's join token.
+func (a *Api) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r, "HeartbeatHandler") {
+		return
+	}
+	d := json.NewDecoder(r.Body)
+	hb := worker.Heartbeat{}
+	err := d.Decode(&hb)
+	if err == nil {
+		err = a.Manager.Heartbeat(hb)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error recording heartbeat: %v\n", err)
+		log.Printf("[manager.Api] [HeartbeatHandler] %s", msg)
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrUnknownNode) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		e := ErrResponse{
+			HTTPStatusCode: status,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,181 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+const (
+	// jobBackoffBase is the delay before the first retry
+	// of a failed job. It doubles with every retry.
+	jobBackoffBase = 10 * time.Second
+	// jobBackoffCap is the longest delay between retries.
+	jobBackoffCap = 6 * time.Minute
+)
+
+// submitJob stores a job that is run as a set of
+// instances. Instances are created by updateJobs.
+// A stop request for the job stops all its instances.
+func (m *Manager) submitJob(te task.TaskEvent) {
+	job := te.Task
+	persisted, err := m.TaskDb.Get(job.ID.String())
+	if err == nil {
+		if te.State == task.Completed && task.IsValidStateTransition(persisted.State, task.Completed) {
+			m.finishJob(persisted, task.Completed, "Stopped")
+		}
+		return
+	}
+	if job.Completions <= 0 {
+		job.Completions = job.Parallelism
+	}
+	if job.Parallelism <= 0 {
+		job.Parallelism = job.Completions
+	}
+	job.State = task.Scheduled
+	m.TaskDb.Put(job.ID.String(), &job)
+	log.Printf("[manager.Manager] [submitJob] Job %s will run %d instances, %d at a time\n", job.ID, job.Completions, job.Parallelism)
+}
+
+// ProcessJobs periodically creates instances
+// of jobs and updates their states.
+func (m *Manager) ProcessJobs() {
+	for {
+		log.Println("[manager.Manager] [ProcessJobs] Processing jobs")
+		m.updateJobs()
+		log.Println("[manager.Manager] [ProcessJobs] Sleeping for 10 seconds")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (m *Manager) updateJobs() {
+	m.mu.Lock()
+	defer m.unlock()
+	tasks := m.listTasks()
+	instances := make(map[uuid.UUID][]*task.Task)
+	for _, t := range tasks {
+		if t.JobID != uuid.Nil {
+			instances[t.JobID] = append(instances[t.JobID], t)
+		}
+	}
+	for _, job := range tasks {
+		if !job.IsJobParent() || (job.State != task.Scheduled && job.State != task.Running) {
+			continue
+		}
+		m.updateJob(job, instances[job.ID])
+	}
+}
+
+// updateJob derives the state of a job from its instances
+// and starts new instances while the job needs more of them.
+func (m *Manager) updateJob(job *task.Task, instances []*task.Task) {
+	succeeded, active := 0, 0
+	for _, t := range instances {
+		switch {
+		case t.State == task.Completed:
+			succeeded++
+		case t.State == task.Failed && t.RestartCount >= t.BackoffLimit:
+			reason := fmt.Sprintf("Instance %s failed with exit code %d", t.ID, t.ExitCode)
+			m.finishJob(job, task.Failed, reason)
+			return
+		default:
+			active++
+		}
+	}
+	if succeeded >= job.Completions {
+		m.finishJob(job, task.Completed, "")
+		return
+	}
+	for i := len(instances); active < job.Parallelism && succeeded+active < job.Completions; i++ {
+		instance := *job
+		instance.ID = uuid.New()
+		instance.Name = fmt.Sprintf("%s-%d", job.Name, i)
+		instance.JobID = job.ID
+		instance.Completions = 0
+		instance.Parallelism = 0
+		instance.Events = nil
+		// Instances are stored before they are scheduled,
+		// so those still waiting in the queue are counted
+		instance.State = task.Pending
+		m.TaskDb.Put(instance.ID.String(), &instance)
+		te := task.TaskEvent{
+			ID:        uuid.New(),
+			State:     task.Scheduled,
+			Timestamp: time.Now(),
+			Task:      instance,
+		}
+		te.Task.State = task.Scheduled
+		m.Pending.Enqueue(te)
+		active++
+		log.Printf("[manager.Manager] [updateJob] Created instance %s of job %s\n", instance.ID, job.ID)
+	}
+	if job.State == task.Scheduled {
+		job.State = task.Running
+		job.StartTime = time.Now().UTC()
+		m.TaskDb.Put(job.ID.String(), job)
+	}
+}
+
+// finishJob moves the job to its final state and stops
+// instances that are still running or waiting to be scheduled.
+func (m *Manager) finishJob(job *task.Task, state task.State, reason string) {
+	for _, t := range m.listTasks() {
+		if t.JobID != job.ID {
+			continue
+		}
+		if t.State == task.Pending {
+			t.Stopped = true
+			t.State = task.Completed
+			t.Reason = "Stopped"
+			m.TaskDb.Put(t.ID.String(), t)
+			continue
+		}
+		w, ok := m.TaskWorkerMap[t.ID]
+		if ok && (t.State == task.Scheduled || t.State == task.Running) {
+			id := t.ID.String()
+			m.afterUnlock(func() {
+				m.stopTask(w, id)
+			})
+		}
+	}
+	job.State = state
+	job.Reason = reason
+	job.FinishTime = time.Now().UTC()
+	m.TaskDb.Put(job.ID.String(), job)
+	log.Printf("[manager.Manager] [finishJob] Job %s finished in state %v %s\n", job.ID, job.State.String()[job.State], reason)
+}
+
+// retryJob restarts a failed job (or an instance of a job)
+// with exponential backoff until BackoffLimit is reached.
+func (m *Manager) retryJob(t *task.Task) {
+	if t.State != task.Failed || t.IsJobParent() {
+		return
+	}
+	if _, ok := m.TaskWorkerMap[t.ID]; !ok {
+		return
+	}
+	if t.RestartCount >= t.BackoffLimit {
+		if t.Reason == "" {
+			t.Reason = "BackoffLimitExceeded"
+			m.TaskDb.Put(t.ID.String(), t)
+		}
+		return
+	}
+	if time.Since(t.FinishTime) < jobBackoff(t.RestartCount) {
+		return
+	}
+	log.Printf("[manager.Manager] [retryJob] Retrying job %s (attempt %d of %d)\n", t.ID, t.RestartCount+1, t.BackoffLimit)
+	m.restartTask(t)
+}
+
+func jobBackoff(retries int) time.Duration {
+	backoff := float64(jobBackoffBase) * math.Pow(2, float64(retries))
+	if backoff > float64(jobBackoffCap) {
+		return jobBackoffCap
+	}
+	return time.Duration(backoff)
+}
@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+func TestJobDefaults(t *testing.T) {
+	tests := []struct {
+		completions, parallelism         int
+		wantCompletions, wantParallelism int
+	}{
+		{3, 0, 3, 3},
+		{0, 2, 2, 2},
+		{4, 2, 4, 2},
+	}
+	for _, tt := range tests {
+		m := newTestManager(t, testWorker)
+		job := task.Task{ID: uuid.New(), Kind: task.Job, Completions: tt.completions, Parallelism: tt.parallelism}
+		m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: job})
+		if _, ok := m.nextTask(); ok {
+			t.Fatal("job parent is scheduled onto a worker")
+		}
+		stored, _ := m.TaskDb.Get(job.ID.String())
+		if stored.Completions != tt.wantCompletions || stored.Parallelism != tt.wantParallelism {
+			t.Errorf("completions %d, parallelism %d: got %d and %d, want %d and %d", tt.completions, tt.parallelism,
+				stored.Completions, stored.Parallelism, tt.wantCompletions, tt.wantParallelism)
+		}
+	}
+}
+
+// submitTestJob submits a job and lets updateJobs create its first instances.
+func submitTestJob(t *testing.T, m *Manager, job task.Task) *task.Task {
+	t.Helper()
+	m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: job})
+	m.nextTask()
+	m.updateJobs()
+	stored, err := m.TaskDb.Get(job.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestJobCompletions(t *testing.T) {
+	m := newTestManager(t, testWorker)
+	job := submitTestJob(t, m, task.Task{ID: uuid.New(), Name: "batch", Kind: task.Job, Completions: 3, Parallelism: 2})
+	if job.State != task.Running {
+		t.Fatalf("job is %v, want Running", job.State.String()[job.State])
+	}
+	if m.Pending.Len() != 2 {
+		t.Fatalf("%d instances are created, want 2", m.Pending.Len())
+	}
+	first := placePending(t, m, testWorker)
+	second := placePending(t, m, testWorker)
+	report(m, testWorker, first.ID, task.Completed, 0)
+	m.updateJobs()
+	if m.Pending.Len() != 1 {
+		t.Fatalf("%d instances are created after the first one has completed, want 1", m.Pending.Len())
+	}
+	third := placePending(t, m, testWorker)
+	for _, instance := range []task.Task{second, third} {
+		if instance.JobID != job.ID {
+			t.Fatalf("instance %s belongs to job %s", instance.ID, instance.JobID)
+		}
+		report(m, testWorker, instance.ID, task.Completed, 0)
+	}
+	m.updateJobs()
+	if m.Pending.Len() != 0 {
+		t.Errorf("%d instances are created after the job has completed", m.Pending.Len())
+	}
+	job, _ = m.TaskDb.Get(job.ID.String())
+	if job.State != task.Completed {
+		t.Errorf("job is %v, want Completed", job.State.String()[job.State])
+	}
+}
+
+func TestJobFailsAfterBackoffLimit(t *testing.T) {
+	m := newTestManager(t, testWorker)
+	job := submitTestJob(t, m, task.Task{ID: uuid.New(), Name: "batch", Kind: task.Job, Completions: 2, BackoffLimit: 1})
+	failing := placePending(t, m, testWorker)
+	placePending(t, m, testWorker)
+	report(m, testWorker, failing.ID, task.Failed, 2)
+	m.updateJobs()
+	job, _ = m.TaskDb.Get(job.ID.String())
+	if job.State != task.Running {
+		t.Fatalf("job is %v before its instance has been retried, want Running", job.State.String()[job.State])
+	}
+	instance, _ := m.TaskDb.Get(failing.ID.String())
+	instance.RestartCount = 1
+	m.updateJobs()
+	job, _ = m.TaskDb.Get(job.ID.String())
+	if job.State != task.Failed {
+		t.Fatalf("job is %v, want Failed", job.State.String()[job.State])
+	}
+	want := fmt.Sprintf("Instance %s failed with exit code 2", failing.ID)
+	if job.Reason != want {
+		t.Errorf("reason = %q, want %q", job.Reason, want)
+	}
+}
+
+func TestJobBackoff(t *testing.T) {
+	tests := []struct {
+		retries int
+		want    time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 20 * time.Second},
+		{5, 320 * time.Second},
+		{6, jobBackoffCap},
+		{20, jobBackoffCap},
+	}
+	for _, tt := range tests {
+		if got := jobBackoff(tt.retries); got != tt.want {
+			t.Errorf("jobBackoff(%d) = %v, want %v", tt.retries, got, tt.want)
+		}
+	}
+}
+
+func TestStopJob(t *testing.T) {
+	m := newTestManager(t, testWorker)
+	job := submitTestJob(t, m, task.Task{ID: uuid.New(), Name: "batch", Kind: task.Job, Completions: 2, Parallelism: 1})
+	placePending(t, m, testWorker)
+	stop := *job
+	stop.State = task.Completed
+	m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Completed, Task: stop})
+	// The instance is stopped after the lock has been released
+	m.SendWork()
+	if len(m.requests) != 0 {
+		t.Errorf("%d requests to workers are left queued", len(m.requests))
+	}
+	job, _ = m.TaskDb.Get(job.ID.String())
+	if job.State != task.Completed || job.Reason != "Stopped" {
+		t.Errorf("job is %v: %q, want Completed: \"Stopped\"", job.State.String()[job.State], job.Reason)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/docker/go-connections/nat"
 	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
 	"github.com/vasilii314/orchestrator/node"
@@ -15,11 +14,15 @@ import (
 	"github.com/vasilii314/orchestrator/worker"
 	"log"
 	"net/http"
-	"strings"
+	"slices"
+	"sync"
 	"time"
 )
 
 type Manager struct {
+	// mu guards the state of the manager shared
+	// by the API handlers and the manager loops
+	mu sync.Mutex
 	// Pending queue stores all task events
 	// before they are submitted to workers.
 	Pending queue.Queue
@@ -28,6 +31,12 @@ type Manager struct {
 	// TaskEventDb (in-memory db) used to track
 	// all task events in the system
 	TaskEventDb store.Store[string, *task.TaskEvent]
+	// CronTaskDb stores cron tasks
+	// keyed by their IDs
+	CronTaskDb store.Store[string, *task.CronTask]
+	// WorkflowDb stores workflows
+	// keyed by their IDs
+	WorkflowDb store.Store[string, *task.Workflow]
 	// Workers slice stores all workers in the system.
 	// Its values are strings of the following pattern:
 	// <hostname>:<port>
@@ -46,12 +55,40 @@ type Manager struct {
 	LastWorker  int
 	WorkerNodes []*node.Node
 	Scheduler   scheduler.Scheduler
+	// Ports allocates host ports
+	// for tasks on every worker
+	Ports *PortAllocator
+	// MissedPolls counts consecutive failed attempts
+	// to poll every worker. Workers that have missed
+	// too many polls are considered down.
+	MissedPolls map[string]int
+	// NodeDb stores nodes of workers that
+	// have registered with the manager
+	NodeDb store.Store[string, *node.Node]
+	// JoinToken authenticates workers
+	// registering with the manager
+	JoinToken string
+	// probing tracks tasks whose probes are running
+	probing map[uuid.UUID]bool
+	// evicting tracks tasks being moved off
+	// their workers by evictTasks
+	evicting map[uuid.UUID]bool
+	// requests to workers queued by afterUnlock
+	requests []func()
 }
 
 // This method is used to schedule tasks
-// recieved from a user onto workers
+// recieved from a user onto workers.
+// The scheduler is given snapshots of the nodes
+// and runs without holding the lock.
 func (m *Manager) SelectWorker(t task.Task) (*node.Node, error) {
-	candidates := m.Scheduler.SelectCandidateNodes(t, m.WorkerNodes)
+	m.mu.Lock()
+	nodes := m.nodeSnapshots()
+	for _, n := range nodes {
+		n.PortsExhausted = !m.Ports.Fits(n.Name, t)
+	}
+	m.mu.Unlock()
+	candidates := m.Scheduler.SelectCandidateNodes(t, nodes)
 	if candidates == nil {
 		msg := fmt.Sprintf("No available candidates match resource request for task %v\n", t.ID)
 		err := errors.New(msg)
@@ -65,41 +102,106 @@ func (m *Manager) SelectWorker(t task.Task) (*node.Node, error) {
 // updateTask polls workers
 // to update task statuses.
 func (m *Manager) updateTasks() {
-	for _, worker := range m.Workers {
+	m.mu.Lock()
+	workers := slices.Clone(m.Workers)
+	m.mu.Unlock()
+	for _, worker := range workers {
 		log.Printf("[manager.Manager] [updateTasks] Checking %v for task updates", worker)
-		url := fmt.Sprintf("http://%s/tasks", worker)
-		resp, err := http.Get(url)
+		// Workers are polled without holding the lock,
+		// so a slow worker does not block the API
+		tasks, err := pollWorker(worker)
+		m.mu.Lock()
+		m.applyTaskUpdates(worker, tasks, err)
+		m.unlock()
+	}
+}
+
+// pollWorker gets the tasks running on the worker.
+func pollWorker(worker string) ([]*task.Task, error) {
+	url := fmt.Sprintf("http://%s/tasks", worker)
+	resp, err := workerClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %v: %v", worker, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker %v returned %s", worker, resp.Status)
+	}
+	var tasks []*task.Task
+	err = json.NewDecoder(resp.Body).Decode(&tasks)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling tasks: %v", err)
+	}
+	return tasks, nil
+}
+
+// applyTaskUpdates copies statuses of tasks
+// reported by the worker to the stored tasks.
+func (m *Manager) applyTaskUpdates(worker string, tasks []*task.Task, err error) {
+	if !slices.Contains(m.Workers, worker) {
+		// The worker has been removed while it was polled
+		return
+	}
+	if err != nil {
+		log.Printf("[manager.Manager] [updateTasks] %v\n", err)
+		m.missPoll(worker)
+		return
+	}
+	m.MissedPolls[worker] = 0
+	m.markSeen(worker)
+	for _, t := range tasks {
+		log.Printf("[manager.Manager] [updateTasks] Attempting to update task %v\n", t.ID)
+		taskPersisited, err := m.TaskDb.Get(t.ID.String())
 		if err != nil {
-			log.Printf("[manager.Manager] [updateTasks] Error connecting to %v: %v\n", worker, err)
+			log.Printf("[manager.Manager] [updateTasks] Task with ID %s not found\n", t.ID)
 			continue
 		}
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("[manager.Manager] [updateTasks] Error sending request: %v\n", err)
+		if m.evicting[t.ID] {
+			// The task is resubmitted by evictTasks
+			// once its instance has been stopped
 			continue
 		}
-		d := json.NewDecoder(resp.Body)
-		var tasks []*task.Task
-		err = d.Decode(&tasks)
-		if err != nil {
-			log.Printf("[manager.Manager] [updateTasks] Error unmarshalling tasks: %s\n", err.Error())
+		assigned, ok := m.TaskWorkerMap[t.ID]
+		if !ok && taskPersisited.Worker == worker {
+			// The assignment has not been restored, e.g. the
+			// manager has been restarted, so the task is adopted
+			m.TaskWorkerMap[t.ID] = worker
+			m.WorkerTaskMap[worker] = append(m.WorkerTaskMap[worker], t.ID)
+			assigned = worker
+		}
+		if assigned != worker {
+			m.fenceTask(worker, t)
 			continue
 		}
-		for _, t := range tasks {
-			log.Printf("[manager.Manager] [updateTasks] Attempting to update task %v\n", t.ID)
-			taskPersisited, err := m.TaskDb.Get(t.ID.String())
-			if err != nil {
-				log.Printf("[manager.Manager] [updateTasks] Task with ID %s not found\n", t.ID)
-				continue
-			}
-			if taskPersisited.State != t.State {
-				taskPersisited.State = t.State
+		// A task waiting to be restarted keeps its state until
+		// the worker reports on the new instance, and a task
+		// that will not be restarted keeps its final state
+		stale := taskPersisited.State == task.Scheduled && t.StartTime.Equal(taskPersisited.StartTime)
+		if taskPersisited.State != t.State && !stale && !isSettled(taskPersisited) {
+			taskPersisited.State = t.State
+			if t.State == task.Completed && taskPersisited.Kind != task.Job && !taskPersisited.Stopped {
+				// Services are not supposed to finish, so
+				// one that has exited on its own has failed
+				taskPersisited.State = task.Failed
+				taskPersisited.Reason = fmt.Sprintf("Service exited with code %d", t.ExitCode)
 			}
-			taskPersisited.StartTime = t.StartTime
-			taskPersisited.FinishTime = t.FinishTime
-			taskPersisited.ContainerID = t.ContainerID
-			taskPersisited.HostPorts = t.HostPorts
-			m.TaskDb.Put(taskPersisited.ID.String(), taskPersisited)
 		}
+		if !isActive(taskPersisited) {
+			// Tasks that have finished and are not
+			// going to be restarted give up their ports
+			m.Ports.Release(worker, t.ID)
+		}
+		taskPersisited.StartTime = t.StartTime
+		taskPersisited.FinishTime = t.FinishTime
+		taskPersisited.ContainerID = t.ContainerID
+		taskPersisited.SidecarIDs = t.SidecarIDs
+		taskPersisited.HostPorts = t.HostPorts
+		if t.Reason != "" && !isSettled(taskPersisited) {
+			taskPersisited.Reason = t.Reason
+		}
+		taskPersisited.ExitCode = t.ExitCode
+		taskPersisited.Events = t.Events
+		m.TaskDb.Put(taskPersisited.ID.String(), taskPersisited)
 	}
 }
 
@@ -122,95 +224,213 @@ func (m *Manager) ProcessTasks() {
 	}
 }
 
-func (m *Manager) stopTask(worker, taskID string) {
-	client := &http.Client{}
+// workerClient is used for requests to workers, so a worker
+// that does not respond does not block the manager loops.
+var workerClient = &http.Client{Timeout: 10 * time.Second}
+
+// afterUnlock queues a request to a worker, which is sent
+// by unlock once the lock is released. The caller holds the lock.
+func (m *Manager) afterUnlock(request func()) {
+	m.requests = append(m.requests, request)
+}
+
+// unlock releases the lock and sends the requests
+// queued by afterUnlock while it has been held.
+func (m *Manager) unlock() {
+	requests := m.requests
+	m.requests = nil
+	m.mu.Unlock()
+	for _, request := range requests {
+		request()
+	}
+}
+
+// stopTask asks the worker to stop the task. It is called
+// without holding the lock, see afterUnlock.
+func (m *Manager) stopTask(worker, taskID string) error {
 	url := fmt.Sprintf("http://%s/tasks/%s", worker, taskID)
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		log.Printf("[manager.Manager] [stopTask] Error creating request to delete task %s: %v\n", taskID, err)
-		return
+		return err
 	}
-	resp, err := client.Do(req)
+	resp, err := workerClient.Do(req)
 	if err != nil {
 		log.Printf("[manager.Manager] [stopTask] Error connecting to worker %s: %v\n", url, err)
-		return
+		return err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 204 {
+		err = fmt.Errorf("worker %s returned %s", worker, resp.Status)
 		log.Printf("[manager.Manager] [stopTask] Error sending request: %v\n", err)
-		return
+		return err
 	}
 	log.Printf("[manager.Manager] [stopTask] Task %s has been scheduled to be stopped", taskID)
+	return nil
 }
 
 func (m *Manager) SendWork() {
-	if m.Pending.Len() > 0 {
-		e := m.Pending.Dequeue()
-		taskEvent := e.(task.TaskEvent)
-		err := m.TaskEventDb.Put(taskEvent.ID.String(), &taskEvent)
+	m.mu.Lock()
+	taskEvent, ok := m.nextTask()
+	m.unlock()
+	if !ok {
+		return
+	}
+	w, err := m.SelectWorker(taskEvent.Task)
+	if err != nil {
+		log.Printf("[manager.Manager] [SendWork] Error selecting worker for task %s: %v\n", taskEvent.Task.ID, err)
+		m.AddTask(taskEvent)
+		return
+	}
+	m.mu.Lock()
+	ok = m.placeTask(&taskEvent, w.Name)
+	m.mu.Unlock()
+	if ok {
+		m.sendTask(w.Name, taskEvent)
+	}
+}
+
+// nextTask takes a task event off the Pending queue and
+// handles it. Tasks that have to be scheduled are returned.
+func (m *Manager) nextTask() (task.TaskEvent, bool) {
+	if m.Pending.Len() == 0 {
+		log.Println("[manager.Manager] [SendWork] No work in the queue")
+		return task.TaskEvent{}, false
+	}
+	e := m.Pending.Dequeue()
+	taskEvent := e.(task.TaskEvent)
+	err := m.TaskEventDb.Put(taskEvent.ID.String(), &taskEvent)
+	if err != nil {
+		log.Printf("[manager.Manager] [SendWork] Error attempting to store task event %s: %v\n", taskEvent.ID.String(), err)
+		return task.TaskEvent{}, false
+	}
+	log.Printf("[manager.Manager] [SendWork] Pulled %v off pending queue\n", taskEvent)
+	if taskEvent.Task.IsJobParent() {
+		m.submitJob(taskEvent)
+		return task.TaskEvent{}, false
+	}
+	taskWorker, ok := m.TaskWorkerMap[taskEvent.Task.ID]
+	if ok {
+		persistedTask, err := m.TaskDb.Get(taskEvent.Task.ID.String())
 		if err != nil {
-			log.Printf("[manager.Manager] [SendWork] Error attempting to store task event %s: %v\n", taskEvent.ID.String(), err)
-			return
+			log.Printf("[manager.Manager] [SendWork] Unable to schedule task^ %s\n", err.Error())
+			return task.TaskEvent{}, false
 		}
-		log.Printf("[manager.Manager] [SendWork] Pulled %v off pending queue\n", taskEvent)
-		taskWorker, ok := m.TaskWorkerMap[taskEvent.Task.ID]
-		if ok {
-			persistedTask, err := m.TaskDb.Get(taskEvent.Task.ID.String())
-			if err != nil {
-				log.Printf("[manager.Manager] [SendWork] Unable to schedule task^ %s\n", err.Error())
-				return
-			}
-			if taskEvent.State == task.Completed && task.IsValidStateTransition(persistedTask.State, taskEvent.State) {
+		if taskEvent.State == task.Completed && task.IsValidStateTransition(persistedTask.State, taskEvent.State) {
+			persistedTask.Stopped = true
+			persistedTask.NextRestartTime = time.Time{}
+			m.TaskDb.Put(persistedTask.ID.String(), persistedTask)
+			m.afterUnlock(func() {
 				m.stopTask(taskWorker, taskEvent.Task.ID.String())
-				return
-			}
-			log.Printf("[manager.Manager] [SendWork] Invalid request: existing task %s is in state %v and cannot transition to the completed state\n", persistedTask.ID.String(), persistedTask.State)
-			return
-		}
-		t := taskEvent.Task
-		w, err := m.SelectWorker(t)
-		if err != nil {
-			log.Printf("[manager.Manager] [SendWork] Error selecting worker for task %s: %v\n", t.ID, err)
-			m.Pending.Enqueue(taskEvent)
-			return
+			})
+			return task.TaskEvent{}, false
 		}
-		m.WorkerTaskMap[w.Name] = append(m.WorkerTaskMap[w.Name], taskEvent.Task.ID)
-		m.TaskWorkerMap[t.ID] = w.Name
-		t.State = task.Scheduled
-		m.TaskDb.Put(t.ID.String(), &t)
-		data, err := json.Marshal(taskEvent)
-		if err != nil {
-			log.Printf("[manager.Manager] [SendWork] Unable to marshal task object^ %v\n", t)
-			m.Pending.Enqueue(taskEvent)
-			return
+		if taskEvent.State == task.Completed && persistedTask.State == task.Failed {
+			// Nothing is running, the task
+			// is just not restarted anymore
+			persistedTask.Stopped = true
+			persistedTask.NextRestartTime = time.Time{}
+			m.TaskDb.Put(persistedTask.ID.String(), persistedTask)
+			return task.TaskEvent{}, false
 		}
-		url := fmt.Sprintf("http://%s/tasks", w.Name)
-		resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
-		if err != nil {
-			log.Printf("[manager.Manager] [SendWork] Error connecting to %v: %v\n", w, err)
-			m.Pending.Enqueue(taskEvent)
-			return
+		log.Printf("[manager.Manager] [SendWork] Invalid request: existing task %s is in state %v and cannot transition to the completed state\n", persistedTask.ID.String(), persistedTask.State)
+		return task.TaskEvent{}, false
+	}
+	if persistedTask, err := m.TaskDb.Get(taskEvent.Task.ID.String()); err == nil {
+		// The task is not assigned to any worker,
+		// e.g. it is lost and waits to be rescheduled
+		if taskEvent.State == task.Completed {
+			persistedTask.Stopped = true
+			persistedTask.State = task.Completed
+			persistedTask.Reason = "Stopped"
+			m.TaskDb.Put(persistedTask.ID.String(), persistedTask)
+			return task.TaskEvent{}, false
 		}
-		d := json.NewDecoder(resp.Body)
-		if resp.StatusCode != http.StatusCreated {
-			e := worker.ErrResponse{}
-			err := d.Decode(&e)
-			if err != nil {
-				log.Printf("[manager.Manager] [SendWork] Error decoding response: %s\n", err.Error())
-				return
-			}
-			log.Printf("[manager.Manager] [SendWork] Response error (%d): %s", e.HTTPStatusCode, e.Message)
-			return
+		if persistedTask.Stopped {
+			log.Printf("[manager.Manager] [SendWork] Task %s has been stopped and is not scheduled\n", persistedTask.ID)
+			return task.TaskEvent{}, false
 		}
-		t = task.Task{}
-		err = d.Decode(&t)
+	}
+	return taskEvent, true
+}
+
+// placeTask assigns the task to the selected worker and
+// allocates its host ports. The task is put back into the
+// queue if the worker can not take it anymore.
+func (m *Manager) placeTask(taskEvent *task.TaskEvent, w string) bool {
+	t := taskEvent.Task
+	if m.getNode(w) == nil {
+		log.Printf("[manager.Manager] [SendWork] Worker %s selected for task %s has been removed\n", w, t.ID)
+		m.Pending.Enqueue(*taskEvent)
+		return false
+	}
+	err := m.Ports.Allocate(w, &t)
+	if err != nil {
+		log.Printf("[manager.Manager] [SendWork] Error allocating ports for task %s: %v\n", t.ID, err)
+		m.Pending.Enqueue(*taskEvent)
+		return false
+	}
+	taskEvent.Task.AllocatedPorts = t.AllocatedPorts
+	m.WorkerTaskMap[w] = append(m.WorkerTaskMap[w], t.ID)
+	m.TaskWorkerMap[t.ID] = w
+	t.Worker = w
+	t.State = task.Scheduled
+	m.TaskDb.Put(t.ID.String(), &t)
+	return true
+}
+
+// sendTask sends a task placed by nextTask to its worker.
+// The lock is only held to handle failures.
+func (m *Manager) sendTask(w string, taskEvent task.TaskEvent) {
+	t := taskEvent.Task
+	data, err := json.Marshal(taskEvent)
+	if err != nil {
+		log.Printf("[manager.Manager] [SendWork] Unable to marshal task object^ %v\n", t)
+		m.AddTask(taskEvent)
+		return
+	}
+	url := fmt.Sprintf("http://%s/tasks", w)
+	resp, err := workerClient.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		log.Printf("[manager.Manager] [SendWork] Error connecting to %v: %v\n", w, err)
+		m.AddTask(taskEvent)
+		return
+	}
+	defer resp.Body.Close()
+	d := json.NewDecoder(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		e := worker.ErrResponse{}
+		err := d.Decode(&e)
 		if err != nil {
 			log.Printf("[manager.Manager] [SendWork] Error decoding response: %s\n", err.Error())
 			return
 		}
-		log.Printf("[manager.Manager] [SendWork] %#v\n", t)
-	} else {
-		log.Println("[manager.Manager] [SendWork] No work in the queue")
+		log.Printf("[manager.Manager] [SendWork] Response error (%d): %s", e.HTTPStatusCode, e.Message)
+		if resp.StatusCode == http.StatusBadRequest {
+			m.mu.Lock()
+			m.Ports.Release(w, t.ID)
+			if persisted, err := m.TaskDb.Get(t.ID.String()); err == nil {
+				m.rejectTask(persisted, e.Message)
+			}
+			m.mu.Unlock()
+		}
+		return
 	}
+	t = task.Task{}
+	err = d.Decode(&t)
+	if err != nil {
+		log.Printf("[manager.Manager] [SendWork] Error decoding response: %s\n", err.Error())
+		return
+	}
+	log.Printf("[manager.Manager] [SendWork] %#v\n", t)
+}
+
+// rejectTask marks a task the worker
+// has refused to run as failed.
+func (m *Manager) rejectTask(t *task.Task, reason string) {
+	t.State = task.Failed
+	t.Reason = reason
+	m.TaskDb.Put(t.ID.String(), t)
 }
 
 func (m *Manager) selectWorkerRoundRobin() int {
@@ -226,10 +446,12 @@ func (m *Manager) selectWorkerRoundRobin() int {
 }
 
 func (m *Manager) AddTask(te task.TaskEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.Pending.Enqueue(te)
 }
 
-func New(workers []string, schedulerType scheduler.SchedulerType, storeType store.StoreType) *Manager {
+func New(workers []string, schedulerType scheduler.SchedulerType, storeType store.StoreType, ports *PortAllocator) *Manager {
 	workerTaskMap := make(map[string][]uuid.UUID)
 	taskWorkerMap := make(map[uuid.UUID]string)
 	var nodes []*node.Node
@@ -255,85 +477,134 @@ func New(workers []string, schedulerType scheduler.SchedulerType, storeType stor
 		TaskWorkerMap: taskWorkerMap,
 		WorkerNodes:   nodes,
 		Scheduler:     s,
+		Ports:         ports,
+		MissedPolls:   make(map[string]int),
+		probing:       make(map[uuid.UUID]bool),
+		evicting:      make(map[uuid.UUID]bool),
 	}
 	var ts store.Store[string, *task.Task]
 	var es store.Store[string, *task.TaskEvent]
+	var cs store.Store[string, *task.CronTask]
+	var ws store.Store[string, *task.Workflow]
+	var ns store.Store[string, *node.Node]
 	switch storeType {
 	case store.InMemoryStore:
 		ts = store.NewInMemoryTaskStore()
 		es = store.NewInMemoryTaskEventStore()
+		cs = store.NewInMemoryObjectStore[*task.CronTask]()
+		ws = store.NewInMemoryObjectStore[*task.Workflow]()
+		ns = store.NewInMemoryObjectStore[*node.Node]()
 	case store.PersistentStore:
 		ts, _ = store.NewPersistentTaskStore("tasks.db", 0600, "tasks")
 		es, _ = store.NewPersistentTaskEventStore("events.db", 0600, "events")
+		cs, _ = store.NewPersistentObjectStore[*task.CronTask]("crontasks.db", 0600, "crontasks")
+		ws, _ = store.NewPersistentObjectStore[*task.Workflow]("workflows.db", 0600, "workflows")
+		ns, _ = store.NewPersistentObjectStore[*node.Node]("nodes.db", 0600, "nodes")
 	default:
 		ts = store.NewInMemoryTaskStore()
 		es = store.NewInMemoryTaskEventStore()
+		cs = store.NewInMemoryObjectStore[*task.CronTask]()
+		ws = store.NewInMemoryObjectStore[*task.Workflow]()
+		ns = store.NewInMemoryObjectStore[*node.Node]()
 	}
 	m.TaskDb = ts
 	m.TaskEventDb = es
+	m.CronTaskDb = cs
+	m.WorkflowDb = ws
+	m.NodeDb = ns
+	m.loadNodes()
+	m.restorePlacement()
 	return &m
 }
 
 func (m *Manager) GetTasks() []*task.Task {
-	taskList, err := m.TaskDb.List()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listTasks()
+}
+
+// GetTask returns a copy of the task with the given ID.
+func (m *Manager) GetTask(id string) (*task.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, err := m.TaskDb.Get(id)
 	if err != nil {
-		log.Printf("[manager.Manager] [GetTasks] Error getting list of tasks: %v\n", err)
-		return nil
+		return nil, err
 	}
-	return taskList
+	tc := *t
+	return &tc, nil
 }
 
-func (m *Manager) checkTaskHealth(t task.Task) error {
-	log.Printf("[manager.Manager] [checkTaskHealth] Calling health check for task %s: %s\n", t.ID, t.HealthCheck)
-	w := m.TaskWorkerMap[t.ID]
-	hostPort := getHostPort(t.HostPorts)
-	worker := strings.Split(w, ":")
-	fmt.Println("FDSDSFDFS", hostPort)
-	fmt.Println(t)
-	url := fmt.Sprintf("http://%s:%s%s", worker[0], *hostPort, t.HealthCheck)
-	log.Printf("[manager.Manager] [checkTaskHealth] Calling health check for task %s: %s\n", t.ID, url)
-	resp, err := http.Get(url)
+// TaskWorker returns the worker the task is assigned to.
+func (m *Manager) TaskWorker(id uuid.UUID) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.TaskWorkerMap[id]
+	return w, ok
+}
+
+func (m *Manager) listTasks() []*task.Task {
+	taskList, err := m.TaskDb.List()
 	if err != nil {
-		msg := fmt.Sprintf("Error connecting to health check endpoint %s", url)
-		log.Println(msg)
-	}
-	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("Error health check for task %s did not return 200\n", t.ID)
-		log.Println(msg)
-		return errors.New(msg)
+		log.Printf("[manager.Manager] [GetTasks] Error getting list of tasks: %v\n", err)
+		return nil
 	}
-	log.Printf("[manager.Manager] [checkTaskHealth] Task %s health check resonse: %v\n", t.ID, resp.StatusCode)
-	return nil
+	return taskList
 }
 
 // getHostPort is a helper function that returns
 // the host port where the task is listening.
-func getHostPort(ports nat.PortMap) *string {
-	for k, _ := range ports {
-		return &ports[k][0].HostPort
+// Task's HealthCheckPort is preferred if set.
+func getHostPort(t task.Task) *string {
+	if t.HealthCheckPort != "" {
+		hostPort, ok := t.HostPort(t.HealthCheckPort)
+		if !ok {
+			return nil
+		}
+		return &hostPort
+	}
+	for k := range t.HostPorts {
+		if len(t.HostPorts[k]) > 0 {
+			return &t.HostPorts[k][0].HostPort
+		}
 	}
 	return nil
 }
 
 func (m *Manager) doHealthChecks() {
-	for _, t := range m.GetTasks() {
-		if t.State == task.Running && t.RestartCount < 3 {
-			err := m.checkTaskHealth(*t)
-			if err != nil {
-				if t.RestartCount < 3 {
-					m.restartTask(t)
-				}
-			}
-		} else if t.State == task.Failed && t.RestartCount < 3 {
-			m.restartTask(t)
+	m.mu.Lock()
+	defer m.unlock()
+	now := time.Now()
+	for _, t := range m.listTasks() {
+		if m.evicting[t.ID] {
+			continue
+		}
+		if t.Kind == task.Job {
+			m.retryJob(t)
+			continue
 		}
+		m.handleRestart(t, now)
 	}
 }
 
+// restartTask schedules the task to be run again on its
+// worker. The task is sent once the lock is released.
 func (m *Manager) restartTask(t *task.Task) {
 	w := m.TaskWorkerMap[t.ID]
+	// Ports of tasks that have finished have been
+	// released, ports still held are kept
+	err := m.Ports.Allocate(w, t)
+	if err != nil {
+		log.Printf("[manager.Manager] [restartTask] Error allocating ports for task %s: %v\n", t.ID, err)
+		return
+	}
 	t.State = task.Scheduled
 	t.RestartCount++
+	t.NextRestartTime = time.Time{}
+	t.Reason = ""
+	t.Health.Reset()
+	t.Readiness.Reset()
+	t.Ready = false
 	m.TaskDb.Put(t.ID.String(), t)
 	taskEvent := task.TaskEvent{
 		ID:        uuid.New(),
@@ -341,44 +612,70 @@ func (m *Manager) restartTask(t *task.Task) {
 		Timestamp: time.Now(),
 		Task:      *t,
 	}
+	m.afterUnlock(func() {
+		m.sendRestart(w, taskEvent)
+	})
+}
+
+// sendRestart sends a task restarted by restartTask to its
+// worker. The lock is only held to handle failures.
+func (m *Manager) sendRestart(w string, taskEvent task.TaskEvent) {
+	t := taskEvent.Task
 	data, err := json.Marshal(taskEvent)
 	if err != nil {
-		log.Printf("Unable to marshal task object: %v\n", t)
+		log.Printf("[manager.Manager] [restartTask] Unable to marshal task object: %v\n", t)
 		return
 	}
 	url := fmt.Sprintf("http://%s/tasks", w)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	resp, err := workerClient.Post(url, "application/json", bytes.NewBuffer(data))
 	if err != nil {
-		log.Printf("Error connecting to %v: %v\n", w, err)
-		m.Pending.Enqueue(t)
+		log.Printf("[manager.Manager] [restartTask] Error connecting to %v: %v\n", w, err)
+		// The restart is retried after a backoff
+		m.failRestart(w, t, fmt.Sprintf("Error connecting to worker %s", w))
 		return
 	}
+	defer resp.Body.Close()
 	d := json.NewDecoder(resp.Body)
 	if resp.StatusCode != http.StatusCreated {
 		e := worker.ErrResponse{}
 		err := d.Decode(&e)
 		if err != nil {
-			fmt.Println("Error decoding response: %s\n", err.Error())
+			log.Printf("[manager.Manager] [restartTask] Error decoding response: %s\n", err.Error())
 			return
 		}
-		log.Printf("Response error (%d): %s", e.HTTPStatusCode, e.Message)
+		log.Printf("[manager.Manager] [restartTask] Response error (%d): %s", e.HTTPStatusCode, e.Message)
+		if resp.StatusCode == http.StatusBadRequest {
+			m.failRestart(w, t, e.Message)
+		}
 		return
 	}
 	newTask := task.Task{}
 	err = d.Decode(&newTask)
 	if err != nil {
-		log.Printf("Error decoding response: %s\n", err.Error())
+		log.Printf("[manager.Manager] [restartTask] Error decoding response: %s\n", err.Error())
+		return
+	}
+	log.Printf("[manager.Manager] [restartTask] %#v\n", newTask)
+}
+
+// failRestart marks a task whose restart has not reached
+// its worker as failed, unless the task has changed since.
+func (m *Manager) failRestart(w string, t task.Task, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	persisted, err := m.TaskDb.Get(t.ID.String())
+	if err != nil || persisted.State != task.Scheduled || persisted.RestartCount != t.RestartCount || m.TaskWorkerMap[t.ID] != w {
 		return
 	}
-	log.Printf("%#v\n", t)
+	m.rejectTask(persisted, reason)
 }
 
 func (m *Manager) DoHealthChecks() {
 	for {
-		log.Println("[manager.Manager] [DoHealthChecks] Performing task health check")
+		log.Println("[manager.Manager] [DoHealthChecks] Restarting failed tasks")
 		m.doHealthChecks()
-		log.Println("[manager.Manager] [DoHealthChecks]  Task health checks completed")
-		log.Println("[manager.Manager] [DoHealthChecks]  Sleeping for 60 seconds")
-		time.Sleep(60 * time.Second)
+		log.Println("[manager.Manager] [DoHealthChecks]  Task restarts completed")
+		log.Println("[manager.Manager] [DoHealthChecks]  Sleeping for 5 seconds")
+		time.Sleep(5 * time.Second)
 	}
 }
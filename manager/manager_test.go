@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/scheduler"
+	"github.com/vasilii314/orchestrator/store"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// testWorker is an address nothing listens at,
+// so requests to the worker fail right away
+const testWorker = "127.0.0.1:1"
+
+func newTestManager(t *testing.T, workers ...string) *Manager {
+	t.Helper()
+	ports, err := NewPortAllocator("30000-30009")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(workers, scheduler.RoundRobinType, store.InMemoryStore, ports)
+}
+
+// placePending takes the next task off the Pending queue and
+// places it on the worker, as SendWork does before sending it.
+func placePending(t *testing.T, m *Manager, worker string) task.Task {
+	t.Helper()
+	te, ok := m.nextTask()
+	if !ok {
+		t.Fatal("no task waits to be scheduled")
+	}
+	if !m.placeTask(&te, worker) {
+		t.Fatalf("task %s has not been placed on worker %s", te.Task.ID, worker)
+	}
+	return te.Task
+}
+
+// report applies the state of the task reported by the worker.
+func report(m *Manager, worker string, id uuid.UUID, state task.State, exitCode int) {
+	m.applyTaskUpdates(worker, []*task.Task{{
+		ID:        id,
+		State:     state,
+		ExitCode:  exitCode,
+		StartTime: time.Now().UTC(),
+	}}, nil)
+}
+
+func TestTaskExitCode(t *testing.T) {
+	tests := []struct {
+		kind     task.Kind
+		stopped  bool
+		state    task.State
+		exitCode int
+		want     task.State
+	}{
+		{task.Service, false, task.Completed, 0, task.Failed},
+		{task.Service, true, task.Completed, 0, task.Completed},
+		{task.Service, false, task.Failed, 1, task.Failed},
+		{task.Job, false, task.Completed, 0, task.Completed},
+		{task.Job, false, task.Failed, 1, task.Failed},
+	}
+	for i, tt := range tests {
+		m := newTestManager(t, testWorker)
+		m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: task.Task{ID: uuid.New(), Kind: tt.kind}})
+		tk := placePending(t, m, testWorker)
+		if tt.stopped {
+			persisted, _ := m.TaskDb.Get(tk.ID.String())
+			persisted.Stopped = true
+		}
+		report(m, testWorker, tk.ID, tt.state, tt.exitCode)
+		persisted, _ := m.TaskDb.Get(tk.ID.String())
+		if persisted.State != tt.want {
+			t.Errorf("case %d: state = %v, want %v", i, persisted.State.String()[persisted.State], tt.want.String()[tt.want])
+		}
+	}
+}
+
+func TestRestartTaskOnUnreachableWorker(t *testing.T) {
+	m := newTestManager(t, testWorker)
+	m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: task.Task{ID: uuid.New()}})
+	tk := placePending(t, m, testWorker)
+	report(m, testWorker, tk.ID, task.Failed, 1)
+	m.doHealthChecks()
+	persisted, _ := m.TaskDb.Get(tk.ID.String())
+	if persisted.NextRestartTime.IsZero() {
+		t.Fatal("restart of the failed task is not scheduled")
+	}
+	persisted.NextRestartTime = time.Now().Add(-time.Second)
+	// The worker is sent the task after the lock has
+	// been released, which is taken again to record
+	// that the worker can not be reached
+	m.doHealthChecks()
+	persisted, _ = m.TaskDb.Get(tk.ID.String())
+	want := "Error connecting to worker " + testWorker
+	if persisted.State != task.Failed || persisted.RestartCount != 1 || persisted.Reason != want {
+		t.Errorf("task is %v after %d restarts: %q, want Failed after 1 restart: %q",
+			persisted.State.String()[persisted.State], persisted.RestartCount, persisted.Reason, want)
+	}
+	if len(m.requests) != 0 {
+		t.Errorf("%d requests to workers are left queued", len(m.requests))
+	}
+}
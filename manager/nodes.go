@@ -0,0 +1,438 @@
+package manager
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/node"
+	"github.com/vasilii314/orchestrator/task"
+	"github.com/vasilii314/orchestrator/worker"
+)
+
+// ErrUnknownNode is returned for
+// workers that have not registered
+var ErrUnknownNode = errors.New("unknown worker")
+
+// NewJoinToken generates a random token
+// workers use to register with the manager.
+func NewJoinToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidJoinToken checks the token a worker registers with.
+func (m *Manager) ValidJoinToken(token string) bool {
+	return m.JoinToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(m.JoinToken)) == 1
+}
+
+// RegisterNode adds the node of a worker, so tasks can be
+// scheduled onto it. Registering again updates the node.
+func (m *Manager) RegisterNode(reg worker.Registration) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if reg.Address == "" {
+		return nil, errors.New("worker address is required")
+	}
+	n := m.getNode(reg.Address)
+	if n == nil {
+		n = node.NewNode(reg.Address, fmt.Sprintf("http://%s", reg.Address), "worker")
+		m.addNode(n)
+		log.Printf("[manager.Manager] [RegisterNode] Worker %s joined at %s\n", reg.Name, reg.Address)
+	}
+	n.WorkerName = reg.Name
+	n.Cores = reg.Cores
+	n.Memory = reg.Memory
+	n.Disk = reg.Disk
+	n.Labels = reg.Labels
+	n.LastSeen = time.Now().UTC()
+	m.MissedPolls[n.Name] = 0
+	m.updateNodeStatus(n, n.LastSeen)
+	return m.nodeSnapshot(n), m.NodeDb.Put(n.Name, n)
+}
+
+// DeregisterNode removes the node of a worker.
+// Its tasks are stopped and scheduled elsewhere.
+func (m *Manager) DeregisterNode(name string) error {
+	m.mu.Lock()
+	defer m.unlock()
+	n := m.getNode(name)
+	if n == nil {
+		return ErrUnknownNode
+	}
+	for _, id := range m.WorkerTaskMap[name] {
+		t, err := m.TaskDb.Get(id.String())
+		if err == nil && (t.State == task.Scheduled || t.State == task.Running) {
+			m.afterUnlock(func() {
+				m.stopTask(name, id.String())
+			})
+		}
+	}
+	m.rescheduleTasks(name, fmt.Sprintf("Worker %s has been removed", name))
+	var workers []string
+	for _, w := range m.Workers {
+		if w != name {
+			workers = append(workers, w)
+		}
+	}
+	var nodes []*node.Node
+	for _, wn := range m.WorkerNodes {
+		if wn != n {
+			nodes = append(nodes, wn)
+		}
+	}
+	m.Workers = workers
+	m.WorkerNodes = nodes
+	delete(m.WorkerTaskMap, name)
+	delete(m.MissedPolls, name)
+	delete(m.Ports.Allocated, name)
+	log.Printf("[manager.Manager] [DeregisterNode] Worker %s has been removed\n", name)
+	return m.NodeDb.Delete(name)
+}
+
+// CordonNode marks the node as unschedulable,
+// or schedulable again when cordon is false.
+func (m *Manager) CordonNode(name string, cordon bool) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cordonNode(name, cordon)
+}
+
+func (m *Manager) cordonNode(name string, cordon bool) (*node.Node, error) {
+	n := m.getNode(name)
+	if n == nil {
+		return nil, ErrUnknownNode
+	}
+	n.Unschedulable = cordon
+	log.Printf("[manager.Manager] [CordonNode] Node %s unschedulable: %v\n", name, cordon)
+	return m.nodeSnapshot(n), m.saveNode(n)
+}
+
+// DrainNode cordons the node and moves its tasks to other
+// nodes. Tasks are stopped gracefully, every one of them
+// within its stop grace period, and replacements are only
+// scheduled once the old instances have had time to exit.
+func (m *Manager) DrainNode(name string) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.cordonNode(name, true)
+	if err != nil {
+		return nil, err
+	}
+	evicted := m.markEvictions(name, func(*task.Task) bool {
+		return true
+	})
+	go m.evictTasks(name, evicted, "Drained", fmt.Sprintf("Worker %s is being drained", name))
+	return m.nodeSnapshot(m.getNode(n.Name)), nil
+}
+
+// TaintNode adds a taint to the node, replacing the taint
+// with the same key and effect. Tasks that do not tolerate
+// a NoExecute taint are evicted from the node the same way
+// DrainNode moves tasks off it.
+func (m *Manager) TaintNode(name string, taint task.Taint) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.getNode(name)
+	if n == nil {
+		return nil, ErrUnknownNode
+	}
+	err := taint.Validate()
+	if err != nil {
+		return nil, err
+	}
+	taints := []task.Taint{taint}
+	for _, t := range n.Taints {
+		if t.Key != taint.Key || t.Effect != taint.Effect {
+			taints = append(taints, t)
+		}
+	}
+	n.Taints = taints
+	log.Printf("[manager.Manager] [TaintNode] Node %s has been tainted with %s\n", name, taint)
+	if taint.Effect == task.NoExecute {
+		evicted := m.markEvictions(name, func(t *task.Task) bool {
+			return !t.Tolerates(taint)
+		})
+		go m.evictTasks(name, evicted, "Evicted", fmt.Sprintf("Worker %s has taint %s", name, taint))
+	}
+	return m.nodeSnapshot(n), m.saveNode(n)
+}
+
+// UntaintNode removes taints with the given key from the node.
+func (m *Manager) UntaintNode(name, key string) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.getNode(name)
+	if n == nil {
+		return nil, ErrUnknownNode
+	}
+	var taints []task.Taint
+	for _, t := range n.Taints {
+		if t.Key != key {
+			taints = append(taints, t)
+		}
+	}
+	n.Taints = taints
+	log.Printf("[manager.Manager] [UntaintNode] Taints %s have been removed from node %s\n", key, name)
+	return m.nodeSnapshot(n), m.saveNode(n)
+}
+
+// evictionDelay covers the worker taking a stop
+// request off its queue before the stop grace
+// period of the task starts.
+const evictionDelay = 15 * time.Second
+
+// markEvictions marks active tasks of the worker selected by
+// evict as being evicted and returns snapshots of them. The
+// manager leaves marked tasks alone until evictTasks is done.
+func (m *Manager) markEvictions(worker string, evict func(*task.Task) bool) []task.Task {
+	var evicted []task.Task
+	for _, id := range m.WorkerTaskMap[worker] {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil || m.evicting[id] || !isActive(t) || !evict(t) {
+			continue
+		}
+		m.evicting[id] = true
+		evicted = append(evicted, *t)
+	}
+	return evicted
+}
+
+// evictTasks stops tasks marked by markEvictions and, once
+// they have had their stop grace period to exit, submits them
+// to the scheduler again, so they are placed on other workers.
+// It is run without holding the lock.
+func (m *Manager) evictTasks(worker string, evicted []task.Task, event, reason string) {
+	var grace time.Duration
+	for _, t := range evicted {
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+		err := m.stopTask(worker, t.ID.String())
+		if err == nil && t.StopGracePeriod() > grace {
+			grace = t.StopGracePeriod()
+		}
+	}
+	if grace > 0 {
+		time.Sleep(grace + evictionDelay)
+	}
+	m.mu.Lock()
+	defer m.unlock()
+	for _, e := range evicted {
+		delete(m.evicting, e.ID)
+		t, err := m.TaskDb.Get(e.ID.String())
+		if err != nil || m.TaskWorkerMap[t.ID] != worker {
+			// The task has been moved meanwhile,
+			// e.g. its worker has been removed
+			continue
+		}
+		delete(m.TaskWorkerMap, t.ID)
+		m.WorkerTaskMap[worker] = slices.DeleteFunc(m.WorkerTaskMap[worker], func(id uuid.UUID) bool {
+			return id == t.ID
+		})
+		m.Ports.Release(worker, t.ID)
+		if t.Stopped {
+			// The task has been stopped meanwhile
+			t.State = task.Completed
+			t.Reason = "Stopped"
+			t.Worker = ""
+			m.TaskDb.Put(t.ID.String(), t)
+			continue
+		}
+		m.resubmitTask(t, task.Pending, event, reason)
+		log.Printf("[manager.Manager] [evictTasks] Task %s is moved off worker %s: %s\n", t.ID, worker, reason)
+	}
+}
+
+// saveNode persists changes of a node, so cordons
+// and taints survive a restart of the manager.
+func (m *Manager) saveNode(n *node.Node) error {
+	return m.NodeDb.Put(n.Name, n)
+}
+
+// nodeSnapshots returns copies of the nodes with labels of
+// tasks placed on every node, so schedulers can honor
+// anti-affinities and topology spread constraints, and
+// resources allocated to them. The nodes are not changed.
+func (m *Manager) nodeSnapshots() []*node.Node {
+	snapshots := make([]*node.Node, 0, len(m.WorkerNodes))
+	for _, n := range m.WorkerNodes {
+		snapshots = append(snapshots, m.nodeSnapshot(n))
+	}
+	return snapshots
+}
+
+func (m *Manager) nodeSnapshot(n *node.Node) *node.Node {
+	s := *n
+	s.Labels = maps.Clone(n.Labels)
+	s.Taints = slices.Clone(n.Taints)
+	s.Conditions = slices.Clone(n.Conditions)
+	s.TaskLabels = make(map[uuid.UUID]map[string]string)
+	s.TaskCount = 0
+	s.CpuAllocated = 0
+	s.MemoryAllocated = 0
+	s.DiskAllocated = 0
+	for _, id := range m.WorkerTaskMap[n.Name] {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil || !isActive(t) {
+			continue
+		}
+		if len(t.Labels) > 0 {
+			s.TaskLabels[id] = maps.Clone(t.Labels)
+		}
+		cpu, memory, disk := t.Resources()
+		s.TaskCount++
+		s.CpuAllocated += cpu
+		s.MemoryAllocated += memory / 1000
+		s.DiskAllocated += disk
+	}
+	s.PortsAllocated = []int{}
+	for port := range m.Ports.Allocated[n.Name] {
+		s.PortsAllocated = append(s.PortsAllocated, port)
+	}
+	slices.Sort(s.PortsAllocated)
+	return &s
+}
+
+// isActive checks whether a task placed on a
+// worker is using, or about to use, its resources.
+func isActive(t *task.Task) bool {
+	return t.State == task.Scheduled || t.State == task.Running || willRestart(t)
+}
+
+// restorePlacement rebuilds assignments of tasks to
+// workers and their host ports from the task store
+// after the manager has been restarted.
+func (m *Manager) restorePlacement() {
+	tasks, err := m.TaskDb.List()
+	if err != nil {
+		log.Printf("[manager.Manager] [restorePlacement] Error getting list of tasks: %v\n", err)
+		return
+	}
+	for _, t := range tasks {
+		if t.Worker == "" || m.getNode(t.Worker) == nil {
+			continue
+		}
+		m.WorkerTaskMap[t.Worker] = append(m.WorkerTaskMap[t.Worker], t.ID)
+		m.TaskWorkerMap[t.ID] = t.Worker
+		if !isActive(t) {
+			continue
+		}
+		err := m.Ports.Allocate(t.Worker, t)
+		if err != nil {
+			log.Printf("[manager.Manager] [restorePlacement] Error allocating ports for task %s: %v\n", t.ID, err)
+		}
+	}
+}
+
+func (m *Manager) addNode(n *node.Node) {
+	m.Workers = append(m.Workers, n.Name)
+	m.WorkerNodes = append(m.WorkerNodes, n)
+	if _, ok := m.WorkerTaskMap[n.Name]; !ok {
+		m.WorkerTaskMap[n.Name] = []uuid.UUID{}
+	}
+}
+
+// loadNodes adds nodes of workers that registered
+// before the manager was restarted and restores cordons
+// and taints of nodes passed with --workers.
+func (m *Manager) loadNodes() {
+	nodes, err := m.NodeDb.List()
+	if err != nil {
+		log.Printf("[manager.Manager] [loadNodes] Error getting list of nodes: %v\n", err)
+		return
+	}
+	for _, n := range nodes {
+		if existing := m.getNode(n.Name); existing != nil {
+			existing.Unschedulable = n.Unschedulable
+			existing.Taints = n.Taints
+			continue
+		}
+		n.Status = node.Unknown
+		m.addNode(n)
+	}
+}
+
+// Heartbeat records a heartbeat sent by a worker.
+func (m *Manager) Heartbeat(hb worker.Heartbeat) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.getNode(hb.Name)
+	if n == nil {
+		return fmt.Errorf("%w %s", ErrUnknownNode, hb.Name)
+	}
+	now := time.Now().UTC()
+	n.LastSeen = now
+	n.Cores = hb.Cores
+	n.Labels = hb.Labels
+	n.SetStats(hb.Stats, now)
+	m.updateNodeStatus(n, now)
+	return nil
+}
+
+// GetNodes returns snapshots of the nodes
+// with tasks and resources placed on them.
+func (m *Manager) GetNodes() []*node.Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nodeSnapshots()
+}
+
+func (m *Manager) getNode(name string) *node.Node {
+	for _, n := range m.WorkerNodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// MonitorNodes periodically updates the status of
+// nodes, so nodes that stop sending heartbeats
+// are not given new tasks.
+func (m *Manager) MonitorNodes() {
+	for {
+		m.updateNodes(time.Now().UTC())
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (m *Manager) updateNodes(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range m.WorkerNodes {
+		m.updateNodeStatus(n, now)
+	}
+}
+
+func (m *Manager) updateNodeStatus(n *node.Node, now time.Time) {
+	message := ""
+	if m.isWorkerDown(n.Name) {
+		message = fmt.Sprintf("Worker has missed %d polls", m.MissedPolls[n.Name])
+	}
+	n.SetCondition(node.NetworkUnavailable, m.isWorkerDown(n.Name), message, now)
+	previous := n.Status
+	if status := n.UpdateStatus(now); status != previous {
+		log.Printf("[manager.Manager] [updateNodeStatus] Node %s is %s (was %s)\n", n.Name, status, previous)
+	}
+}
+
+// markSeen records that the worker
+// has been reached by the manager.
+func (m *Manager) markSeen(worker string) {
+	if n := m.getNode(worker); n != nil {
+		n.LastSeen = time.Now().UTC()
+		m.updateNodeStatus(n, n.LastSeen)
+	}
+}
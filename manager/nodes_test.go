@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// waitEvicted waits until evictTasks is done with all tasks.
+func waitEvicted(t *testing.T, m *Manager) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		m.mu.Lock()
+		n := len(m.evicting)
+		m.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("tasks are still being evicted")
+}
+
+func TestDrainNode(t *testing.T) {
+	tests := []struct {
+		stopped    bool
+		wantState  task.State
+		wantQueued int
+	}{
+		{false, task.Pending, 1},
+		{true, task.Completed, 0},
+	}
+	for _, tt := range tests {
+		m := newTestManager(t, testWorker)
+		m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: task.Task{ID: uuid.New()}})
+		tk := placePending(t, m, testWorker)
+		report(m, testWorker, tk.ID, task.Running, 0)
+		if tt.stopped {
+			// The task is stopped while it is being evicted
+			persisted, _ := m.TaskDb.Get(tk.ID.String())
+			persisted.Stopped = true
+		}
+		_, err := m.DrainNode(testWorker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitEvicted(t, m)
+		persisted, _ := m.TaskDb.Get(tk.ID.String())
+		if persisted.State != tt.wantState {
+			t.Errorf("stopped %v: task is %v, want %v", tt.stopped, persisted.State.String()[persisted.State], tt.wantState.String()[tt.wantState])
+		}
+		if _, ok := m.TaskWorkerMap[tk.ID]; ok || len(m.WorkerTaskMap[testWorker]) != 0 {
+			t.Errorf("stopped %v: task is still assigned to the drained worker", tt.stopped)
+		}
+		if m.Pending.Len() != tt.wantQueued {
+			t.Errorf("stopped %v: %d tasks are queued, want %d", tt.stopped, m.Pending.Len(), tt.wantQueued)
+		}
+	}
+}
+
+func TestTaintNodeEvictsTasks(t *testing.T) {
+	m := newTestManager(t, testWorker)
+	taint := task.Taint{Key: "maintenance", Effect: task.NoExecute}
+	tolerating := task.Task{ID: uuid.New(), Tolerations: []task.Toleration{{Key: "maintenance", Operator: task.TolerationExists}}}
+	evicted := task.Task{ID: uuid.New()}
+	for _, tk := range []task.Task{tolerating, evicted} {
+		m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: tk})
+		placePending(t, m, testWorker)
+		report(m, testWorker, tk.ID, task.Running, 0)
+	}
+	_, err := m.TaintNode(testWorker, taint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitEvicted(t, m)
+	if w := m.TaskWorkerMap[tolerating.ID]; w != testWorker {
+		t.Errorf("task tolerating the taint is moved to worker %q", w)
+	}
+	if _, ok := m.TaskWorkerMap[evicted.ID]; ok {
+		t.Error("task not tolerating the taint is still assigned to the worker")
+	}
+	persisted, _ := m.TaskDb.Get(evicted.ID.String())
+	if persisted.State != task.Pending || m.Pending.Len() != 1 {
+		t.Errorf("evicted task is %v with %d tasks queued, want Pending with 1 queued", persisted.State.String()[persisted.State], m.Pending.Len())
+	}
+}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// PortAllocator hands out host ports to tasks
+// from a range of ports. Every worker has its
+// own pool, so the same port can be used by tasks
+// running on different workers.
+type PortAllocator struct {
+	// Start and End are the first and
+	// the last port of the range
+	Start int
+	End   int
+	// Allocated tracks ports used on every worker
+	// and the tasks they are allocated to
+	Allocated map[string]map[int]uuid.UUID
+}
+
+// NewPortAllocator creates an allocator from
+// a range in the form of <start>-<end>.
+func NewPortAllocator(portRange string) (*PortAllocator, error) {
+	bounds := strings.Split(portRange, "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("invalid port range %q", portRange)
+	}
+	start, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid port range %q: %v", portRange, err)
+	}
+	end, err := strconv.Atoi(bounds[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid port range %q: %v", portRange, err)
+	}
+	if start <= 0 || end > 65535 || start > end {
+		return nil, fmt.Errorf("invalid port range %q", portRange)
+	}
+	return &PortAllocator{
+		Start:     start,
+		End:       end,
+		Allocated: make(map[string]map[int]uuid.UUID),
+	}, nil
+}
+
+// Fits checks whether all ports of task t
+// can be allocated on the worker.
+func (p *PortAllocator) Fits(worker string, t task.Task) bool {
+	used := p.Allocated[worker]
+	bound := make(map[int]bool)
+	for _, hostPort := range t.PortBindings {
+		port, err := strconv.Atoi(hostPort)
+		if err != nil {
+			return false
+		}
+		if id, ok := used[port]; ok && id != t.ID {
+			return false
+		}
+		bound[port] = true
+	}
+	needed := 0
+	for port := range t.Ports() {
+		if _, ok := t.PortBindings[string(port)]; !ok {
+			needed++
+		}
+	}
+	free := 0
+	for port := p.Start; port <= p.End && free < needed; port++ {
+		if id, ok := used[port]; (!ok || id == t.ID) && !bound[port] {
+			free++
+		}
+	}
+	return free >= needed
+}
+
+// Allocate reserves host ports explicitly bound by task t
+// and binds each of its remaining exposed ports, including
+// ports of its sidecars, to a free port from the range.
+// Allocated ports are kept apart from explicit bindings in
+// t.AllocatedPorts. Ports allocated before, e.g. on another
+// worker, are reused if they are free on this worker.
+func (p *PortAllocator) Allocate(worker string, t *task.Task) error {
+	if !p.Fits(worker, *t) {
+		return fmt.Errorf("ports of task %v do not fit on worker %s", t.ID, worker)
+	}
+	used, ok := p.Allocated[worker]
+	if !ok {
+		used = make(map[int]uuid.UUID)
+		p.Allocated[worker] = used
+	}
+	for _, hostPort := range t.PortBindings {
+		port, _ := strconv.Atoi(hostPort)
+		used[port] = t.ID
+	}
+	previous := t.AllocatedPorts
+	t.AllocatedPorts = make(map[string]string)
+	for exposed := range t.Ports() {
+		if _, ok := t.PortBindings[string(exposed)]; ok {
+			continue
+		}
+		port, err := strconv.Atoi(previous[string(exposed)])
+		if id, ok := used[port]; err != nil || port < p.Start || port > p.End || (ok && id != t.ID) {
+			port, err = p.next(used)
+			if err != nil {
+				return err
+			}
+		}
+		used[port] = t.ID
+		t.AllocatedPorts[string(exposed)] = strconv.Itoa(port)
+	}
+	return nil
+}
+
+// Release frees all ports allocated to the task on the worker.
+func (p *PortAllocator) Release(worker string, taskID uuid.UUID) {
+	for port, id := range p.Allocated[worker] {
+		if id == taskID {
+			delete(p.Allocated[worker], port)
+		}
+	}
+}
+
+func (p *PortAllocator) next(used map[int]uuid.UUID) (int, error) {
+	for port := p.Start; port <= p.End; port++ {
+		if _, ok := used[port]; !ok {
+			return port, nil
+		}
+	}
+	return 0, errors.New("no free ports left in the range")
+}
@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+func TestNewPortAllocator(t *testing.T) {
+	tests := []struct {
+		portRange  string
+		start, end int
+		wantErr    bool
+	}{
+		{"30000-32767", 30000, 32767, false},
+		{"8080-8080", 8080, 8080, false},
+		{"30000", 0, 0, true},
+		{"a-b", 0, 0, true},
+		{"0-100", 0, 0, true},
+		{"100-70000", 0, 0, true},
+		{"200-100", 0, 0, true},
+	}
+	for _, tt := range tests {
+		p, err := NewPortAllocator(tt.portRange)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v, want error %v", tt.portRange, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (p.Start != tt.start || p.End != tt.end) {
+			t.Errorf("%q: range %d-%d, want %d-%d", tt.portRange, p.Start, p.End, tt.start, tt.end)
+		}
+	}
+}
+
+func portTask(bindings map[string]string, exposed ...string) *task.Task {
+	t := &task.Task{ID: uuid.New(), ExposedPorts: nat.PortSet{}, PortBindings: bindings}
+	for _, port := range exposed {
+		t.ExposedPorts[nat.Port(port)] = struct{}{}
+	}
+	return t
+}
+
+func TestAllocatePorts(t *testing.T) {
+	tests := []struct {
+		name string
+		// used are ports taken by another task on the worker
+		used []int
+		task *task.Task
+		// want maps exposed ports to their host ports,
+		// nil if the ports do not fit
+		want map[string]string
+	}{
+		{
+			name: "allocated in order",
+			task: portTask(nil, "80/tcp"),
+			want: map[string]string{"80/tcp": "30000"},
+		},
+		{
+			name: "used ports skipped",
+			used: []int{30000, 30001},
+			task: portTask(nil, "80/tcp"),
+			want: map[string]string{"80/tcp": "30002"},
+		},
+		{
+			name: "explicit binding",
+			task: portTask(map[string]string{"80/tcp": "30000"}, "80/tcp", "443/tcp"),
+			want: map[string]string{"443/tcp": "30001"},
+		},
+		{
+			name: "explicit binding taken",
+			used: []int{8080},
+			task: portTask(map[string]string{"80/tcp": "8080"}, "80/tcp"),
+		},
+		{
+			name: "range exhausted",
+			used: []int{30000, 30001, 30002},
+			task: portTask(nil, "80/tcp"),
+		},
+		{
+			name: "sidecar ports",
+			task: &task.Task{ID: uuid.New(), Sidecars: []task.Container{{Name: "proxy", ExposedPorts: nat.PortSet{"9090/tcp": {}}}}},
+			want: map[string]string{"9090/tcp": "30000"},
+		},
+	}
+	for _, tt := range tests {
+		p := &PortAllocator{Start: 30000, End: 30002, Allocated: map[string]map[int]uuid.UUID{"w1": {}}}
+		other := uuid.New()
+		for _, port := range tt.used {
+			p.Allocated["w1"][port] = other
+		}
+		err := p.Allocate("w1", tt.task)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: ports %v allocated, want an error", tt.name, tt.task.AllocatedPorts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if len(tt.task.AllocatedPorts) != len(tt.want) {
+			t.Errorf("%s: allocated %v, want %v", tt.name, tt.task.AllocatedPorts, tt.want)
+		}
+		for port, hostPort := range tt.want {
+			if tt.task.AllocatedPorts[port] != hostPort {
+				t.Errorf("%s: allocated %v, want %v", tt.name, tt.task.AllocatedPorts, tt.want)
+			}
+		}
+	}
+}
+
+func TestAllocatePortsOnAnotherWorker(t *testing.T) {
+	p := &PortAllocator{Start: 30000, End: 30002, Allocated: make(map[string]map[int]uuid.UUID)}
+	tk := portTask(map[string]string{"443/tcp": "30002"}, "80/tcp", "443/tcp")
+	err := p.Allocate("w1", tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.AllocatedPorts["80/tcp"] != "30000" {
+		t.Fatalf("allocated %v on w1", tk.AllocatedPorts)
+	}
+	// The port allocated on w1 is taken on w2
+	p.Allocated["w2"] = map[int]uuid.UUID{30000: uuid.New()}
+	p.Release("w1", tk.ID)
+	err = p.Allocate("w2", tk)
+	if err != nil {
+		t.Fatalf("task does not fail over to w2: %v", err)
+	}
+	if tk.AllocatedPorts["80/tcp"] != "30001" || tk.PortBindings["443/tcp"] != "30002" || len(tk.PortBindings) != 1 {
+		t.Errorf("allocated %v, bound %v on w2", tk.AllocatedPorts, tk.PortBindings)
+	}
+	if len(p.Allocated["w1"]) != 0 {
+		t.Errorf("ports %v are still allocated on w1", p.Allocated["w1"])
+	}
+	// Ports held by the task are kept when it is restarted
+	err = p.Allocate("w2", tk)
+	if err != nil || tk.AllocatedPorts["80/tcp"] != "30001" {
+		t.Errorf("allocated %v after a restart: %v", tk.AllocatedPorts, err)
+	}
+}
@@ -0,0 +1,384 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+	"golang.org/x/net/http2"
+)
+
+// ProbeTasks runs liveness and readiness probes of
+// running tasks, every one of them at its own interval.
+func (m *Manager) ProbeTasks() {
+	for {
+		m.probeTasks(time.Now())
+		time.Sleep(time.Second)
+	}
+}
+
+func (m *Manager) probeTasks(now time.Time) {
+	m.mu.Lock()
+	tasks := m.listTasks()
+	m.mu.Unlock()
+	for _, t := range tasks {
+		pt, ok := m.probeTarget(t.ID, now)
+		if !ok {
+			continue
+		}
+		// Every task is probed in its own goroutine,
+		// so a slow probe does not delay the others
+		go func() {
+			defer m.probeDone(pt.ID)
+			m.probeTask(pt, now)
+		}()
+	}
+}
+
+// probeTask runs probes of the task that are due. The lock is
+// not held while probes run, so they do not block the API.
+func (m *Manager) probeTask(t task.Task, now time.Time) {
+	// Failed readiness checks only take
+	// the task out of traffic
+	if p := t.ReadinessProbe; p != nil && probeDue(&t, p, t.Readiness, now) {
+		result := m.probe(t, p)
+		if !result.Success {
+			log.Printf("[manager.Manager] [probeTasks] Readiness check of task %s failed: %s\n", t.ID, result.Message)
+		}
+		m.recordProbe(t, func(pt *task.Task) {
+			pt.Readiness.Record(p, result)
+		})
+	}
+
+	if p := livenessProbe(&t); p != nil && probeDue(&t, p, t.Health, now) {
+		result := m.probe(t, p)
+		if !result.Success {
+			log.Printf("[manager.Manager] [probeTasks] Health check of task %s failed: %s\n", t.ID, result.Message)
+		}
+		// Unhealthy tasks are restarted by doHealthChecks
+		m.recordProbe(t, func(pt *task.Task) {
+			pt.Health.Record(p, result)
+		})
+	}
+}
+
+// livenessProbe returns the health probe of the task.
+// Jobs are retried by retryJob instead.
+func livenessProbe(t *task.Task) *task.Probe {
+	if t.Kind == task.Job {
+		return nil
+	}
+	return t.Probe()
+}
+
+// probeTarget returns a copy of the task to probe, with
+// the worker it runs on, when any of its probes is due and
+// it is not being probed yet. Tasks that are not running
+// are taken out of traffic.
+func (m *Manager) probeTarget(id uuid.UUID, now time.Time) (task.Task, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, err := m.TaskDb.Get(id.String())
+	if err != nil {
+		return task.Task{}, false
+	}
+	if ready := t.IsReady(); ready != t.Ready {
+		t.Ready = ready
+		m.TaskDb.Put(t.ID.String(), t)
+	}
+	if t.State != task.Running || m.probing[t.ID] {
+		return task.Task{}, false
+	}
+	readiness := t.ReadinessProbe != nil && probeDue(t, t.ReadinessProbe, t.Readiness, now)
+	liveness := livenessProbe(t) != nil && probeDue(t, livenessProbe(t), t.Health, now)
+	if !readiness && !liveness {
+		return task.Task{}, false
+	}
+	m.probing[t.ID] = true
+	tc := *t
+	tc.Worker = m.TaskWorkerMap[t.ID]
+	return tc, true
+}
+
+// probeDone marks the probes of the task as finished.
+func (m *Manager) probeDone(id uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.probing, id)
+}
+
+// recordProbe records the result of a probe, unless the
+// task has been stopped or restarted while it was probed.
+func (m *Manager) recordProbe(probed task.Task, record func(*task.Task)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, err := m.TaskDb.Get(probed.ID.String())
+	if err != nil || t.State != task.Running || !t.StartTime.Equal(probed.StartTime) {
+		return
+	}
+	record(t)
+	t.Ready = t.IsReady()
+	m.TaskDb.Put(t.ID.String(), t)
+}
+
+// probeDue checks whether it is time
+// to run probe p of task t again.
+func probeDue(t *task.Task, p *task.Probe, h task.Health, now time.Time) bool {
+	return !now.Before(t.StartTime.Add(p.InitialDelay())) && now.Sub(h.LastCheck) >= p.Period()
+}
+
+// probe runs a single check of task t.
+func (m *Manager) probe(t task.Task, p *task.Probe) task.ProbeResult {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout())
+	defer cancel()
+	start := time.Now()
+	var err error
+	switch {
+	case p.HTTP != nil:
+		err = m.probeHTTP(ctx, t, p.HTTP)
+	case p.TCP != nil:
+		err = m.probeTCP(ctx, t, p.TCP)
+	case p.Exec != nil:
+		err = m.probeExec(ctx, t, p.Exec)
+	case p.GRPC != nil:
+		err = m.probeGRPC(ctx, t, p.GRPC)
+	}
+	result := task.ProbeResult{
+		Time:     start.UTC(),
+		Success:  err == nil,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Message = err.Error()
+	}
+	return result
+}
+
+// taskAddress returns <host>:<port> the given port of task t
+// is reachable on. The health check port of the task is used
+// when port is empty.
+func (m *Manager) taskAddress(t task.Task, port string) (string, error) {
+	var hostPort *string
+	if port == "" {
+		hostPort = getHostPort(t)
+	} else if p, ok := t.HostPort(port); ok {
+		hostPort = &p
+	}
+	if hostPort == nil {
+		return "", fmt.Errorf("no published port found for task %s", t.ID)
+	}
+	host := strings.Split(t.Worker, ":")[0]
+	return net.JoinHostPort(host, *hostPort), nil
+}
+
+func (m *Manager) probeHTTP(ctx context.Context, t task.Task, p *task.HTTPProbe) error {
+	addr, err := m.taskAddress(t, p.Port)
+	if err != nil {
+		return err
+	}
+	method := p.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	url := fmt.Sprintf("http://%s%s", addr, p.Path)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if len(p.ExpectedStatus) == 0 {
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("%s returned %s", url, resp.Status)
+		}
+		return nil
+	}
+	for _, code := range p.ExpectedStatus {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s returned %s, expected one of %v", url, resp.Status, p.ExpectedStatus)
+}
+
+func (m *Manager) probeTCP(ctx context.Context, t task.Task, p *task.TCPProbe) error {
+	addr, err := m.taskAddress(t, p.Port)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeExec runs the command through the
+// exec API of the task's worker.
+func (m *Manager) probeExec(ctx context.Context, t task.Task, p *task.ExecProbe) error {
+	data, err := json.Marshal(task.ExecOptions{Cmd: p.Command})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("http://%s/tasks/%s/exec", t.Worker, t.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("worker returned %s", resp.Status)
+	}
+	result := task.ExecResult{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("command exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// grpcServingStatus names values of
+// grpc.health.v1.HealthCheckResponse.ServingStatus
+var grpcServingStatus = []string{"UNKNOWN", "SERVING", "NOT_SERVING", "SERVICE_UNKNOWN"}
+
+// probeGRPC calls grpc.health.v1.Health/Check over
+// cleartext HTTP/2. Messages are small enough to be
+// encoded by hand.
+func (m *Manager) probeGRPC(ctx context.Context, t task.Task, p *task.GRPCProbe) error {
+	addr, err := m.taskAddress(t, p.Port)
+	if err != nil {
+		return err
+	}
+	// HealthCheckRequest has a single string field: service = 1
+	var msg []byte
+	if p.Service != "" {
+		msg = append(msg, 0x0a)
+		msg = binary.AppendUvarint(msg, uint64(len(p.Service)))
+		msg = append(msg, p.Service...)
+	}
+	// Every gRPC message is prefixed with a compression
+	// flag and its length
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	url := fmt.Sprintf("http://%s/grpc.health.v1.Health/Check", addr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// Errors without a message are sent in headers only
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		return fmt.Errorf("gRPC status %s: %s", status, message)
+	}
+	if len(data) < 5 || len(data) < 5+int(binary.BigEndian.Uint32(data[1:5])) {
+		return errors.New("malformed gRPC health check response")
+	}
+	servingStatus, err := decodeServingStatus(data[5 : 5+binary.BigEndian.Uint32(data[1:5])])
+	if err != nil {
+		return err
+	}
+	if servingStatus != 1 {
+		name := fmt.Sprint(servingStatus)
+		if servingStatus < uint64(len(grpcServingStatus)) {
+			name = grpcServingStatus[servingStatus]
+		}
+		return fmt.Errorf("service is %s", name)
+	}
+	return nil
+}
+
+// decodeServingStatus reads field status = 1 of
+// HealthCheckResponse, skipping unknown fields.
+func decodeServingStatus(msg []byte) (uint64, error) {
+	var status uint64
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, errors.New("malformed gRPC health check response")
+		}
+		msg = msg[n:]
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			msg = msg[n:]
+			if key>>3 == 1 {
+				status = v
+			}
+		case 1:
+			if len(msg) < 8 {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			msg = msg[8:]
+		case 2:
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			msg = msg[n+int(l):]
+		case 5:
+			if len(msg) < 4 {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			msg = msg[4:]
+		default:
+			return 0, fmt.Errorf("unsupported wire type %d in gRPC health check response", key&7)
+		}
+	}
+	return status, nil
+}
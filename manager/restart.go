@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/vasilii314/orchestrator/task"
+)
+
+const (
+	// restartBackoffBase is the delay before the first
+	// restart of a task. It doubles with every restart.
+	restartBackoffBase = 10 * time.Second
+	// restartBackoffCap is the longest delay between restarts.
+	restartBackoffCap = 5 * time.Minute
+)
+
+// handleRestart restarts a service that has stopped running
+// or has become unhealthy according to its restart policy.
+// A restart is delayed by an exponential backoff. Tasks that
+// can not be restarted are left in a terminal state.
+func (m *Manager) handleRestart(t *task.Task, now time.Time) {
+	if !needsRestart(t) {
+		return
+	}
+	if _, ok := m.TaskWorkerMap[t.ID]; !ok {
+		return
+	}
+	if !t.CanRestart() {
+		m.settleTask(t, now)
+		return
+	}
+	if t.NextRestartTime.IsZero() {
+		t.RestartBackoff = restartBackoff(t.RestartCount)
+		t.NextRestartTime = now.Add(t.RestartBackoff).UTC()
+		m.TaskDb.Put(t.ID.String(), t)
+		log.Printf("[manager.Manager] [handleRestart] Task %s will be restarted in %v\n", t.ID, t.RestartBackoff)
+		return
+	}
+	if now.Before(t.NextRestartTime) {
+		return
+	}
+	log.Printf("[manager.Manager] [handleRestart] Restarting task %s (restart %d)\n", t.ID, t.RestartCount+1)
+	m.restartTask(t)
+}
+
+// needsRestart checks whether a service is down or unhealthy
+// and its restart policy asks for it to be restarted.
+func needsRestart(t *task.Task) bool {
+	if t.Stopped || isSettled(t) {
+		return false
+	}
+	switch t.State {
+	case task.Failed:
+		return true
+	case task.Running:
+		return t.Health.Status == task.Unhealthy
+	case task.Completed:
+		return t.Policy() == task.RestartAlways
+	}
+	return false
+}
+
+// settleTask leaves a task that is not going to be
+// restarted anymore in its terminal state with a reason.
+// Unhealthy tasks are stopped and marked as failed.
+func (m *Manager) settleTask(t *task.Task, now time.Time) {
+	if t.Policy() == task.RestartNever && t.State != task.Running {
+		return
+	}
+	reason := "RestartLimitExceeded"
+	if t.Policy() == task.RestartNever {
+		reason = "RestartPolicyNever"
+	}
+	switch {
+	case t.State == task.Running:
+		w, id := m.TaskWorkerMap[t.ID], t.ID.String()
+		m.afterUnlock(func() {
+			m.stopTask(w, id)
+		})
+		t.State = task.Failed
+		t.Reason = fmt.Sprintf("%s: task is unhealthy", reason)
+		t.FinishTime = now.UTC()
+	case t.Reason != "":
+		t.Reason = fmt.Sprintf("%s: %s", reason, t.Reason)
+	default:
+		t.Reason = reason
+	}
+	t.NextRestartTime = time.Time{}
+	t.Settled = true
+	m.TaskDb.Put(t.ID.String(), t)
+	log.Printf("[manager.Manager] [settleTask] Task %s will not be restarted: %s\n", t.ID, t.Reason)
+}
+
+// isSettled checks whether the task has been
+// left in its final state by settleTask.
+func isSettled(t *task.Task) bool {
+	return t.Settled
+}
+
+// restartBackoff returns the delay before the next restart
+// of a task restarted the given number of times. A random
+// jitter of up to a half of the delay keeps tasks that have
+// failed together from being restarted at the same time.
+func restartBackoff(restarts int) time.Duration {
+	backoff := float64(restartBackoffBase) * math.Pow(2, float64(restarts))
+	if backoff > float64(restartBackoffCap) {
+		backoff = float64(restartBackoffCap)
+	}
+	return time.Duration(backoff/2 + rand.Float64()*backoff/2)
+}
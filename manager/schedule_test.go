@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"*/15 0-6,22 1 * 1-5", false},
+		{"5/10 * * * *", false},
+		{"0 0 * * 7", false},
+		{"@daily", false},
+		{" @hourly ", false},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"a * * * *", true},
+		{"@every 5m", true},
+	}
+	for _, tt := range tests {
+		_, err := parseCron(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v, want error %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	from := time.Date(2024, 1, 1, 10, 30, 20, 0, time.UTC)
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, time.Date(2024, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", from, time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", from, time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * *", from, time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"@hourly", from, time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", from, time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", from, time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		// Restricted day of month and day of week are combined with OR
+		{"0 0 15 * 5", from, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 3 * 5", from, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		// Day of week with a star day of month only matches Fridays
+		{"0 0 * * 5", from, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", from, time.Time{}},
+		{"30 10 * * *", from, time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.expr, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q after %v: got %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextInTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	s, err := parseCron("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	want := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	if got := s.Next(from.In(loc)); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got.UTC(), want)
+	}
+}
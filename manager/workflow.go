@@ -0,0 +1,225 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// AddWorkflow validates and stores a workflow and submits
+// its tasks that do not depend on other tasks.
+func (m *Manager) AddWorkflow(w *task.Workflow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := w.Validate()
+	if err != nil {
+		return err
+	}
+	switch w.FailurePolicy {
+	case "":
+		w.FailurePolicy = task.SkipDownstream
+	case task.SkipDownstream, task.FailDownstream:
+	default:
+		return fmt.Errorf("unknown failure policy %q", w.FailurePolicy)
+	}
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	w.State = task.Scheduled
+	w.CreationTime = time.Now().UTC()
+	w.FinishTime = time.Time{}
+	for i := range w.Tasks {
+		w.Tasks[i].TaskID = uuid.Nil
+		w.Tasks[i].State = task.Pending
+		w.Tasks[i].Reason = ""
+	}
+	m.updateWorkflow(w)
+	return m.WorkflowDb.Put(w.ID.String(), w)
+}
+
+func (m *Manager) GetWorkflows() []*task.Workflow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listWorkflows()
+}
+
+// GetWorkflow returns the workflow with the given ID.
+func (m *Manager) GetWorkflow(id string) (*task.Workflow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.WorkflowDb.Get(id)
+}
+
+func (m *Manager) listWorkflows() []*task.Workflow {
+	workflows, err := m.WorkflowDb.List()
+	if err != nil {
+		log.Printf("[manager.Manager] [GetWorkflows] Error getting list of workflows: %v\n", err)
+		return nil
+	}
+	return workflows
+}
+
+// ProcessWorkflows periodically submits tasks of workflows
+// whose dependencies have completed and updates workflow states.
+func (m *Manager) ProcessWorkflows() {
+	for {
+		log.Println("[manager.Manager] [ProcessWorkflows] Processing workflows")
+		m.updateWorkflows()
+		log.Println("[manager.Manager] [ProcessWorkflows] Sleeping for 10 seconds")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (m *Manager) updateWorkflows() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.listWorkflows() {
+		if w.State != task.Scheduled && w.State != task.Running {
+			continue
+		}
+		m.updateWorkflow(w)
+		m.WorkflowDb.Put(w.ID.String(), w)
+	}
+}
+
+// dependencyState is the outcome of a workflow member
+// as seen by the tasks depending on it.
+type dependencyState int
+
+const (
+	dependencyPending dependencyState = iota
+	dependencyCompleted
+	dependencyFailed
+)
+
+// updateWorkflow copies states of submitted tasks to the
+// members of the workflow, submits members that are ready,
+// marks members with failed dependencies and finally
+// derives the state of the workflow.
+func (m *Manager) updateWorkflow(w *task.Workflow) {
+	members := make(map[string]*task.WorkflowTask)
+	outcomes := make(map[string]dependencyState)
+	for i := range w.Tasks {
+		wt := &w.Tasks[i]
+		members[wt.Name] = wt
+		outcomes[wt.Name] = m.updateWorkflowTask(wt)
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := range w.Tasks {
+			wt := &w.Tasks[i]
+			if wt.State != task.Pending || wt.TaskID != uuid.Nil {
+				continue
+			}
+			ready := true
+			for _, dep := range wt.DependsOn {
+				switch outcomes[dep] {
+				case dependencyFailed:
+					m.failWorkflowTask(w, wt, dep)
+					outcomes[wt.Name] = dependencyFailed
+					changed = true
+				case dependencyPending:
+					ready = false
+				}
+				if wt.State != task.Pending {
+					break
+				}
+			}
+			if ready && wt.State == task.Pending {
+				m.submitWorkflowTask(w, wt)
+			}
+		}
+	}
+
+	finished, succeeded, submitted := 0, 0, 0
+	for _, wt := range w.Tasks {
+		if wt.TaskID != uuid.Nil {
+			submitted++
+		}
+		switch outcomes[wt.Name] {
+		case dependencyCompleted:
+			finished++
+			succeeded++
+		case dependencyFailed:
+			finished++
+		}
+	}
+	switch {
+	case finished < len(w.Tasks) && submitted > 0:
+		w.State = task.Running
+	case finished < len(w.Tasks):
+		w.State = task.Scheduled
+	case succeeded == len(w.Tasks):
+		w.State = task.Completed
+	default:
+		w.State = task.Failed
+	}
+	if finished == len(w.Tasks) {
+		w.FinishTime = time.Now().UTC()
+		log.Printf("[manager.Manager] [updateWorkflow] Workflow %s finished in state %v\n", w.ID, w.State.String()[w.State])
+	}
+}
+
+// updateWorkflowTask copies the state of the submitted
+// task to the member and returns its outcome.
+func (m *Manager) updateWorkflowTask(wt *task.WorkflowTask) dependencyState {
+	if wt.TaskID == uuid.Nil {
+		if wt.State == task.Skipped || wt.State == task.Failed {
+			return dependencyFailed
+		}
+		return dependencyPending
+	}
+	t, err := m.TaskDb.Get(wt.TaskID.String())
+	if err != nil {
+		// The task is still in the Pending queue
+		return dependencyPending
+	}
+	wt.State = t.State
+	if t.Reason != "" {
+		wt.Reason = t.Reason
+	}
+	done, succeeded := taskFinished(t)
+	switch {
+	case !done:
+		return dependencyPending
+	case succeeded:
+		return dependencyCompleted
+	default:
+		return dependencyFailed
+	}
+}
+
+// submitWorkflowTask adds a task of the member to the Pending
+// queue. Members are expected to finish, so they are run as jobs.
+func (m *Manager) submitWorkflowTask(w *task.Workflow, wt *task.WorkflowTask) {
+	t := wt.Task
+	t.ID = uuid.New()
+	if t.Name == "" {
+		t.Name = fmt.Sprintf("%s-%s", w.Name, wt.Name)
+	}
+	t.Kind = task.Job
+	t.State = task.Pending
+	m.Pending.Enqueue(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      t,
+	})
+	wt.TaskID = t.ID
+	wt.State = task.Scheduled
+	log.Printf("[manager.Manager] [submitWorkflowTask] Submitted task %s of workflow %s\n", wt.Name, w.ID)
+}
+
+// failWorkflowTask marks a member whose dependency has
+// failed according to the failure policy of the workflow.
+func (m *Manager) failWorkflowTask(w *task.Workflow, wt *task.WorkflowTask, dep string) {
+	wt.State = task.Skipped
+	if w.FailurePolicy == task.FailDownstream {
+		wt.State = task.Failed
+	}
+	wt.Reason = fmt.Sprintf("Dependency %s did not complete", dep)
+	log.Printf("[manager.Manager] [failWorkflowTask] Task %s of workflow %s is %v: %s\n", wt.Name, w.ID, wt.State.String()[wt.State], wt.Reason)
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/vasilii314/orchestrator/task"
+)
+
+func TestWorkflowTaskExitsWithZero(t *testing.T) {
+	m := newTestManager(t, testWorker)
+	w := &task.Workflow{
+		Name: "build",
+		Tasks: []task.WorkflowTask{
+			{Name: "compile"},
+			{Name: "test", DependsOn: []string{"compile"}},
+		},
+	}
+	err := m.AddWorkflow(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"compile", "test"} {
+		tk := placePending(t, m, testWorker)
+		if tk.Kind != task.Job {
+			t.Fatalf("task %s is submitted as %q, want %q", name, tk.Kind, task.Job)
+		}
+		report(m, testWorker, tk.ID, task.Completed, 0)
+		m.updateWorkflows()
+		persisted, _ := m.TaskDb.Get(tk.ID.String())
+		if persisted.State != task.Completed {
+			t.Fatalf("task %s is %v, want Completed", name, persisted.State.String()[persisted.State])
+		}
+	}
+	w, _ = m.GetWorkflow(w.ID.String())
+	if w.State != task.Completed {
+		t.Errorf("workflow is %v, want Completed", w.State.String()[w.State])
+	}
+}
+
+func TestWorkflowFailurePolicy(t *testing.T) {
+	tests := []struct {
+		policy task.FailurePolicy
+		want   task.State
+	}{
+		{task.SkipDownstream, task.Skipped},
+		{task.FailDownstream, task.Failed},
+	}
+	for _, tt := range tests {
+		m := newTestManager(t, testWorker)
+		w := &task.Workflow{
+			Name: "build",
+			Tasks: []task.WorkflowTask{
+				{Name: "compile"},
+				{Name: "test", DependsOn: []string{"compile"}},
+				{Name: "publish", DependsOn: []string{"test"}},
+			},
+			FailurePolicy: tt.policy,
+		}
+		err := m.AddWorkflow(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk := placePending(t, m, testWorker)
+		report(m, testWorker, tk.ID, task.Failed, 1)
+		m.updateWorkflows()
+		if m.Pending.Len() != 0 {
+			t.Errorf("%s: %d dependents are submitted after their dependency has failed", tt.policy, m.Pending.Len())
+		}
+		w, _ = m.GetWorkflow(w.ID.String())
+		for _, wt := range w.Tasks[1:] {
+			if wt.State != tt.want {
+				t.Errorf("%s: task %s is %v, want %v", tt.policy, wt.Name, wt.State.String()[wt.State], tt.want.String()[tt.want])
+			}
+		}
+		if w.State != task.Failed {
+			t.Errorf("%s: workflow is %v, want Failed", tt.policy, w.State.String()[w.State])
+		}
+	}
+}
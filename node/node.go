@@ -4,17 +4,67 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/task"
 	"github.com/vasilii314/orchestrator/utils"
 	"github.com/vasilii314/orchestrator/worker"
 	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
+type Status string
+
+const (
+	// Ready nodes are up and can run new tasks
+	Ready Status = "Ready"
+	// NotReady nodes are up, but one of
+	// their conditions prevents new tasks
+	// from being scheduled onto them
+	NotReady Status = "NotReady"
+	// Unknown nodes have not been seen
+	// by the manager for too long
+	Unknown Status = "Unknown"
+)
+
+type ConditionType string
+
+const (
+	// MemoryPressure means the node is running out of memory
+	MemoryPressure ConditionType = "MemoryPressure"
+	// DiskPressure means the node is running out of disk space
+	DiskPressure ConditionType = "DiskPressure"
+	// NetworkUnavailable means the manager
+	// is unable to reach the node's API
+	NetworkUnavailable ConditionType = "NetworkUnavailable"
+)
+
+const (
+	// HeartbeatTimeout is how long a node can go unseen
+	// before its status becomes Unknown
+	HeartbeatTimeout = 40 * time.Second
+	// pressureThreshold is the fraction of memory or disk
+	// space that has to be available on the node
+	pressureThreshold = 0.1
+)
+
+// Condition describes an aspect of the node's
+// state. Active conditions make the node NotReady.
+type Condition struct {
+	Type               ConditionType
+	Active             bool
+	Message            string
+	LastTransitionTime time.Time
+}
+
 // Node is a representation of a physical machine
 // that Worker, or Manager is running on
 type Node struct {
+	// Name is the <hostname>:<port> address of the
+	// worker's API, WorkerName is the worker's own name
 	Name            string
+	WorkerName      string
 	Ip              string
 	Api             string
 	Cores           int
@@ -23,15 +73,43 @@ type Node struct {
 	Disk            int64
 	DiskAllocated   int64
 	Role            string
-	TaskCount       int
-	Stats           worker.Stats
+	// Labels are reported by the worker, e.g. disk=ssd
+	Labels map[string]string
+	// TaskCount and allocations sum up tasks placed on
+	// the node that are active or about to be restarted.
+	// MemoryAllocated is in kilobytes and DiskAllocated is
+	// in bytes, same as Memory and Disk. The manager
+	// refreshes them from its task store.
+	TaskCount      int
+	CpuAllocated   float64
+	PortsAllocated []int
+	// PortsExhausted is only set on snapshots given to
+	// the scheduler, for nodes without free host ports
+	// for the task being scheduled
+	PortsExhausted bool `json:"-"`
+	// TaskLabels holds labels of tasks placed on
+	// the node keyed by their IDs. It is refreshed
+	// by the manager before tasks are scheduled.
+	TaskLabels map[uuid.UUID]map[string]string
+	Stats      worker.Stats
+	Status     Status
+	// Unschedulable (cordoned) nodes keep their
+	// tasks, but are not given new ones
+	Unschedulable bool
+	// Taints repel tasks that do not tolerate them
+	Taints []task.Taint
+	// LastSeen is the time of the last heartbeat
+	// or successful poll of the node
+	LastSeen   time.Time
+	Conditions []Condition
 }
 
 func NewNode(name, api, role string) *Node {
 	return &Node{
-		Name: name,
-		Api:  api,
-		Role: role,
+		Name:   name,
+		Api:    api,
+		Role:   role,
+		Status: Unknown,
 	}
 }
 
@@ -61,8 +139,73 @@ func (n *Node) GetStats() (*worker.Stats, error) {
 		log.Printf("[Epvm] [getNodeStats] %s\n", msg)
 		return nil, errors.New(msg)
 	}
-	n.Memory = int64(stats.MemTotalKb())
-	n.Disk = int64(stats.DiskTotal())
-	n.Stats = stats
+	n.SetStats(stats, time.Now().UTC())
 	return &stats, nil
 }
+
+// SetStats records stats reported by the node
+// and updates its pressure conditions.
+func (n *Node) SetStats(stats worker.Stats, now time.Time) {
+	n.Stats = stats
+	if stats.MemStats != nil {
+		n.Memory = int64(stats.MemTotalKb())
+		available := float64(stats.MemAvailableKb())
+		pressure := stats.MemTotalKb() > 0 && available < pressureThreshold*float64(stats.MemTotalKb())
+		n.SetCondition(MemoryPressure, pressure, fmt.Sprintf("%d kB of %d kB of memory available", stats.MemAvailableKb(), stats.MemTotalKb()), now)
+	}
+	if stats.DiskStats != nil {
+		n.Disk = int64(stats.DiskTotal())
+		free := float64(stats.DiskFree())
+		pressure := stats.DiskTotal() > 0 && free < pressureThreshold*float64(stats.DiskTotal())
+		n.SetCondition(DiskPressure, pressure, fmt.Sprintf("%d of %d bytes of disk space free", stats.DiskFree(), stats.DiskTotal()), now)
+	}
+}
+
+// SetCondition sets the condition of the given type,
+// adding it if the node does not have it yet.
+func (n *Node) SetCondition(t ConditionType, active bool, message string, now time.Time) {
+	for i := range n.Conditions {
+		c := &n.Conditions[i]
+		if c.Type != t {
+			continue
+		}
+		if c.Active != active {
+			c.LastTransitionTime = now
+		}
+		c.Active = active
+		c.Message = message
+		return
+	}
+	n.Conditions = append(n.Conditions, Condition{
+		Type:               t,
+		Active:             active,
+		Message:            message,
+		LastTransitionTime: now,
+	})
+}
+
+// UpdateStatus derives the status of the node from
+// the time it was last seen and its conditions.
+func (n *Node) UpdateStatus(now time.Time) Status {
+	n.Status = Ready
+	if n.LastSeen.IsZero() || now.Sub(n.LastSeen) > HeartbeatTimeout {
+		n.Status = Unknown
+		return n.Status
+	}
+	for _, c := range n.Conditions {
+		if c.Active {
+			n.Status = NotReady
+		}
+	}
+	return n.Status
+}
+
+func (n *Node) IsReady() bool {
+	return n.Status == Ready
+}
+
+// Schedulable checks whether new
+// tasks can be placed on the node.
+func (n *Node) Schedulable() bool {
+	return n.IsReady() && !n.Unschedulable
+}
@@ -11,10 +11,11 @@ type Epvm struct {
 	Name string
 }
 
-// SelectCandidateNodes selects nodes that have
-// enough of disk space to run task t.
+// SelectCandidateNodes selects schedulable nodes
+// that have enough of disk space to run task t.
 func (e *Epvm) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
 	var candidates []*node.Node
+	nodes = schedulableNodes(t, nodes)
 	for i := range nodes {
 		if checkDiskSpace(t, nodes[i].Disk-nodes[i].DiskAllocated) {
 			candidates = append(candidates, nodes[i])
@@ -24,14 +25,18 @@ func (e *Epvm) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Nod
 }
 
 func checkDiskSpace(t task.Task, diskSpaceAvailable int64) bool {
-	return t.Disk <= diskSpaceAvailable
+	_, _, disk := t.Resources()
+	return disk <= diskSpaceAvailable
 }
 
 // Score E-PVM implementation is based on https://mosix.cs.huji.ac.il/pub/ocja.pdf
+// Nodes missing preferred node affinities of task t or having
+// PreferNoSchedule taints it does not tolerate get a higher cost.
 func (e *Epvm) Score(t task.Task, nodes []*node.Node) map[string]float64 {
 	nodeScores := make(map[string]float64)
 	maxJobs := 4.0
 	n := float64(len(nodes))
+	penalties := nodePenalties(t, nodes)
 	for _, node := range nodes {
 		cpuUsage, err := calculateCpuUsage(node)
 		if err != nil {
@@ -40,12 +45,14 @@ func (e *Epvm) Score(t task.Task, nodes []*node.Node) map[string]float64 {
 		cpuLoad := calculateLoad(cpuUsage, math.Pow(2, 0.8))
 		memoryAllocated := float64(node.Stats.MemUsedKb()) + float64(node.MemoryAllocated)
 		memoryPercentAllocated := memoryAllocated / float64(node.Memory)
-		newMemPercent := calculateLoad(memoryAllocated+float64(t.Memory/1000), float64(node.Memory))
+		_, memory, _ := t.Resources()
+		newMemPercent := calculateLoad(memoryAllocated+float64(memory/1000), float64(node.Memory))
 		memCost := math.Pow(n, newMemPercent) + math.Pow(n, float64(node.TaskCount+1)/maxJobs) -
 			math.Pow(n, memoryPercentAllocated) - math.Pow(n, float64(node.TaskCount)/float64(maxJobs))
 		cpuCost := math.Pow(n, cpuLoad) + math.Pow(n, float64(node.TaskCount+1)/maxJobs) -
 			math.Pow(n, cpuLoad) - math.Pow(n, float64(node.TaskCount)/float64(maxJobs))
-		nodeScores[node.Name] = memCost + cpuCost
+		// Every point of penalty adds 1 to the cost
+		nodeScores[node.Name] = memCost + cpuCost + float64(penalties[node.Name])
 	}
 	return nodeScores
 }
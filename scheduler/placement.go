@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/node"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// topology returns the value of the topology key of
+// node n. An empty key makes every node its own group.
+func topology(n *node.Node, key string) (string, bool) {
+	if key == "" {
+		return n.Name, true
+	}
+	value, ok := n.Labels[key]
+	return value, ok
+}
+
+// countTasks counts tasks other than the
+// given one matching the selector on node n.
+func countTasks(n *node.Node, id uuid.UUID, selector map[string]string) int {
+	count := 0
+	for taskID, labels := range n.TaskLabels {
+		if taskID != id && task.MatchLabels(selector, labels) {
+			count++
+		}
+	}
+	return count
+}
+
+// violatesAntiAffinity checks whether placing task t on
+// node n puts it in the same topology group as a task
+// it keeps away from. Nodes are all nodes of the cluster.
+func violatesAntiAffinity(t task.Task, n *node.Node, nodes []*node.Node) bool {
+	for _, a := range t.AntiAffinity {
+		value, ok := topology(n, a.TopologyKey)
+		if !ok {
+			continue
+		}
+		for _, other := range nodes {
+			if v, ok := topology(other, a.TopologyKey); ok && v == value && countTasks(other, t.ID, a.Selector) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// spread keeps candidates where placing task t does not
+// make the difference between the number of matching tasks
+// in their topology group and the group with the fewest
+// of them exceed the max skew. Groups are made of the
+// candidates, matching tasks are counted on all nodes.
+func spread(t task.Task, c task.TopologySpreadConstraint, candidates, nodes []*node.Node) []*node.Node {
+	selector := c.Selector
+	if len(selector) == 0 {
+		selector = t.Labels
+	}
+	counts := make(map[string]int)
+	for _, n := range candidates {
+		if value, ok := topology(n, c.TopologyKey); ok {
+			counts[value] = 0
+		}
+	}
+	for _, n := range nodes {
+		value, ok := topology(n, c.TopologyKey)
+		if _, group := counts[value]; ok && group {
+			counts[value] += countTasks(n, t.ID, selector)
+		}
+	}
+	least := -1
+	for _, count := range counts {
+		if least == -1 || count < least {
+			least = count
+		}
+	}
+	var kept []*node.Node
+	for _, n := range candidates {
+		value, ok := topology(n, c.TopologyKey)
+		if ok && counts[value]+1-least <= c.Skew() {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/node"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// testNode returns a ready node in the zone
+// running tasks with the given labels.
+func testNode(name, zone string, tasks ...map[string]string) *node.Node {
+	n := &node.Node{
+		Name:       name,
+		Status:     node.Ready,
+		Labels:     map[string]string{"zone": zone},
+		TaskLabels: make(map[uuid.UUID]map[string]string),
+	}
+	for _, labels := range tasks {
+		n.TaskLabels[uuid.New()] = labels
+	}
+	return n
+}
+
+func names(nodes []*node.Node) []string {
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func TestViolatesAntiAffinity(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	nodes := []*node.Node{
+		testNode("n1", "a", web),
+		testNode("n2", "a"),
+		testNode("n3", "b", map[string]string{"app": "db"}),
+	}
+	tests := []struct {
+		name string
+		a    task.TaskAntiAffinity
+		want []string
+	}{
+		{"node", task.TaskAntiAffinity{Selector: web}, []string{"n2", "n3"}},
+		{"zone", task.TaskAntiAffinity{Selector: web, TopologyKey: "zone"}, []string{"n3"}},
+		{"missing key", task.TaskAntiAffinity{Selector: web, TopologyKey: "rack"}, []string{"n1", "n2", "n3"}},
+		{"no match", task.TaskAntiAffinity{Selector: map[string]string{"app": "cache"}, TopologyKey: "zone"}, []string{"n1", "n2", "n3"}},
+	}
+	for _, tt := range tests {
+		tk := task.Task{ID: uuid.New(), AntiAffinity: []task.TaskAntiAffinity{tt.a}}
+		var got []string
+		for _, n := range nodes {
+			if !violatesAntiAffinity(tk, n, nodes) {
+				got = append(got, n.Name)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: allowed nodes %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSpread(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	nodes := []*node.Node{
+		testNode("n1", "a", web, web),
+		testNode("n2", "a"),
+		testNode("n3", "b", web),
+		testNode("n4", "c"),
+	}
+	tests := []struct {
+		name       string
+		c          task.TopologySpreadConstraint
+		candidates []*node.Node
+		want       []string
+	}{
+		{"nodes", task.TopologySpreadConstraint{}, nodes, []string{"n2", "n4"}},
+		{"nodes skew 2", task.TopologySpreadConstraint{MaxSkew: 2}, nodes, []string{"n2", "n3", "n4"}},
+		{"zones", task.TopologySpreadConstraint{TopologyKey: "zone"}, nodes, []string{"n4"}},
+		{"zones skew 2", task.TopologySpreadConstraint{TopologyKey: "zone", MaxSkew: 2}, nodes, []string{"n3", "n4"}},
+		// Groups are made of candidates, tasks are counted on all nodes
+		{"zone candidates", task.TopologySpreadConstraint{TopologyKey: "zone"}, nodes[:3], []string{"n3"}},
+		{"selector", task.TopologySpreadConstraint{Selector: map[string]string{"app": "db"}}, nodes, []string{"n1", "n2", "n3", "n4"}},
+	}
+	for _, tt := range tests {
+		tk := task.Task{ID: uuid.New(), Labels: web}
+		got := names(spread(tk, tt.c, tt.candidates, nodes))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: kept %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulableNodesWithoutFreePorts(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	tests := []struct {
+		name  string
+		tk    task.Task
+		nodes []*node.Node
+		want  []string
+	}{
+		{
+			// The node without free ports does not keep
+			// the spread from choosing the other zone
+			"spread",
+			task.Task{Labels: web, TopologySpread: []task.TopologySpreadConstraint{{TopologyKey: "zone"}}},
+			[]*node.Node{testNode("n1", "a"), testNode("n2", "b", web)},
+			[]string{"n2"},
+		},
+		{
+			// Tasks on the node without free ports
+			// are still kept away from
+			"anti-affinity",
+			task.Task{AntiAffinity: []task.TaskAntiAffinity{{Selector: web, TopologyKey: "zone"}}},
+			[]*node.Node{testNode("n1", "a", web), testNode("n2", "a"), testNode("n3", "b")},
+			[]string{"n3"},
+		},
+	}
+	for _, tt := range tests {
+		tt.tk.ID = uuid.New()
+		tt.nodes[0].PortsExhausted = true
+		got := names(schedulableNodes(tt.tk, tt.nodes))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: schedulable nodes %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
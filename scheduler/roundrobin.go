@@ -10,21 +10,35 @@ type RoundRobin struct {
 	LastWorker int
 }
 
+// SelectCandidateNodes selects nodes that are ready, have
+// not been cordoned and match node selectors of task t.
 func (r *RoundRobin) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
-	return nodes
+	return schedulableNodes(t, nodes)
 }
 
+// Score takes turns among nodes that fit task t best
+// by its preferred node affinities and PreferNoSchedule
+// taints. Other nodes score worse the bigger their penalty.
 func (r *RoundRobin) Score(t task.Task, nodes []*node.Node) map[string]float64 {
 	nodeScores := make(map[string]float64)
+	penalties := nodePenalties(t, nodes)
+	var preferred []*node.Node
+	for _, node := range nodes {
+		if penalties[node.Name] == 0 {
+			preferred = append(preferred, node)
+		} else {
+			nodeScores[node.Name] = 1.0 + float64(penalties[node.Name])
+		}
+	}
 	var newWorker int
-	if r.LastWorker+1 < len(nodes) {
+	if r.LastWorker+1 < len(preferred) {
 		newWorker = r.LastWorker + 1
 		r.LastWorker++
 	} else {
 		newWorker = 0
 		r.LastWorker = 0
 	}
-	for i, node := range nodes {
+	for i, node := range preferred {
 		if i == newWorker {
 			nodeScores[node.Name] = 0.1
 		} else {
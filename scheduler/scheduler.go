@@ -17,3 +17,52 @@ type Scheduler interface {
 	Score(t task.Task, nodes []*node.Node) map[string]float64
 	Pick(scores map[string]float64, candidates []*node.Node) *node.Node
 }
+
+// schedulableNodes filters out nodes that are not ready,
+// have been cordoned, have no free host ports for task t,
+// do not match its node selector and required node
+// affinity, would break its anti-affinities and topology
+// spread constraints or have NoSchedule or NoExecute taints
+// it does not tolerate. Every scheduler selects candidates
+// among the remaining nodes. Filtered out nodes still count
+// towards anti-affinities and spread constraints.
+func schedulableNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	var schedulable []*node.Node
+	for _, n := range nodes {
+		if !n.Schedulable() || n.PortsExhausted || !t.MatchesNode(n.Labels) || violatesAntiAffinity(t, n, nodes) {
+			continue
+		}
+		if len(t.UntoleratedTaints(n.Taints, task.NoSchedule)) == 0 && len(t.UntoleratedTaints(n.Taints, task.NoExecute)) == 0 {
+			schedulable = append(schedulable, n)
+		}
+	}
+	for _, c := range t.TopologySpread {
+		schedulable = spread(t, c, schedulable, nodes)
+	}
+	return schedulable
+}
+
+// taintPenalty is the penalty of every PreferNoSchedule
+// taint a task does not tolerate. It outweighs any
+// preferred node affinity.
+const taintPenalty = 100
+
+// nodePenalties returns how much worse a fit every node is for
+// task t than the best fitting node, which gets 0. Nodes are
+// penalized by the weight of preferred node affinities they
+// miss and by PreferNoSchedule taints t does not tolerate.
+func nodePenalties(t task.Task, nodes []*node.Node) map[string]int {
+	penalties := make(map[string]int)
+	least := 0
+	for i, n := range nodes {
+		penalty := -t.NodePreference(n.Labels) + taintPenalty*len(t.UntoleratedTaints(n.Taints, task.PreferNoSchedule))
+		penalties[n.Name] = penalty
+		if i == 0 || penalty < least {
+			least = penalty
+		}
+	}
+	for name := range penalties {
+		penalties[name] -= least
+	}
+	return penalties
+}
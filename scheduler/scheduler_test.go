@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/node"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+func TestSchedulableNodesWithTaints(t *testing.T) {
+	tainted := func(name string, taints ...task.Taint) *node.Node {
+		n := testNode(name, "a")
+		n.Taints = taints
+		return n
+	}
+	nodes := []*node.Node{
+		tainted("n1"),
+		tainted("n2", task.Taint{Key: "team", Value: "data", Effect: task.NoSchedule}),
+		tainted("n3", task.Taint{Key: "maintenance", Effect: task.NoExecute}),
+		tainted("n4", task.Taint{Key: "spot", Effect: task.PreferNoSchedule}),
+	}
+	tests := []struct {
+		name        string
+		tolerations []task.Toleration
+		want        []string
+	}{
+		{"no tolerations", nil, []string{"n1", "n4"}},
+		{"no schedule", []task.Toleration{{Key: "team", Value: "data"}}, []string{"n1", "n2", "n4"}},
+		{"no execute", []task.Toleration{{Key: "maintenance", Operator: task.TolerationExists, Effect: task.NoExecute}}, []string{"n1", "n3", "n4"}},
+		{"everything", []task.Toleration{{Operator: task.TolerationExists}}, []string{"n1", "n2", "n3", "n4"}},
+	}
+	for _, tt := range tests {
+		tk := task.Task{ID: uuid.New(), Tolerations: tt.tolerations}
+		got := names(schedulableNodes(tk, nodes))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: schedulable nodes %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNodePenalties(t *testing.T) {
+	n1 := testNode("n1", "a")
+	n2 := testNode("n2", "b")
+	n2.Taints = []task.Taint{{Key: "spot", Effect: task.PreferNoSchedule}}
+	tk := task.Task{NodeAffinity: &task.NodeAffinity{Preferred: []task.PreferredNodeAffinity{
+		{Weight: 50, NodeSelectorRequirement: task.NodeSelectorRequirement{Key: "zone", Operator: task.In, Values: []string{"b"}}},
+	}}}
+	penalties := nodePenalties(tk, []*node.Node{n1, n2})
+	// The taint outweighs the preferred zone
+	if penalties["n1"] != 0 || penalties["n2"] != taintPenalty-50 {
+		t.Errorf("penalties %v, want n1 0 and n2 %d", penalties, taintPenalty-50)
+	}
+	tk.Tolerations = []task.Toleration{{Key: "spot", Operator: task.TolerationExists}}
+	penalties = nodePenalties(tk, []*node.Node{n1, n2})
+	if penalties["n1"] != 50 || penalties["n2"] != 0 {
+		t.Errorf("penalties %v with the taint tolerated, want n1 50 and n2 0", penalties)
+	}
+}
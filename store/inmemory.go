@@ -40,6 +40,11 @@ func (i *InMemoryTaskStore) Count() (int, error) {
 	return len(i.Db), nil
 }
 
+func (i *InMemoryTaskStore) Delete(key string) error {
+	delete(i.Db, key)
+	return nil
+}
+
 type InMemoryTaskEventStore struct {
 	Db map[string]*task.TaskEvent
 }
@@ -74,3 +79,50 @@ func (i *InMemoryTaskEventStore) List() ([]*task.TaskEvent, error) {
 func (i *InMemoryTaskEventStore) Count() (int, error) {
 	return len(i.Db), nil
 }
+
+func (i *InMemoryTaskEventStore) Delete(key string) error {
+	delete(i.Db, key)
+	return nil
+}
+
+// InMemoryObjectStore is a store for values
+// of any type that have no dedicated store.
+type InMemoryObjectStore[V any] struct {
+	Db map[string]V
+}
+
+func NewInMemoryObjectStore[V any]() *InMemoryObjectStore[V] {
+	return &InMemoryObjectStore[V]{
+		Db: make(map[string]V),
+	}
+}
+
+func (i *InMemoryObjectStore[V]) Put(key string, v V) error {
+	i.Db[key] = v
+	return nil
+}
+
+func (i *InMemoryObjectStore[V]) Get(key string) (V, error) {
+	v, ok := i.Db[key]
+	if !ok {
+		return v, fmt.Errorf("value with key %s does not exist", key)
+	}
+	return v, nil
+}
+
+func (i *InMemoryObjectStore[V]) List() ([]V, error) {
+	values := make([]V, 0, len(i.Db))
+	for _, v := range i.Db {
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (i *InMemoryObjectStore[V]) Count() (int, error) {
+	return len(i.Db), nil
+}
+
+func (i *InMemoryObjectStore[V]) Delete(key string) error {
+	delete(i.Db, key)
+	return nil
+}
@@ -99,6 +99,13 @@ func (t *PersistentTaskStore) Get(key string) (*task.Task, error) {
 	return &task, nil
 }
 
+func (t *PersistentTaskStore) Delete(key string) error {
+	return t.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(t.Bucket))
+		return b.Delete([]byte(key))
+	})
+}
+
 func (t *PersistentTaskStore) List() ([]*task.Task, error) {
 	var tasks []*task.Task
 	err := t.Db.View(func(tx *bolt.Tx) error {
@@ -210,6 +217,13 @@ func (e *PersistentTaskEventStore) Get(key string) (*task.TaskEvent, error) {
 	return &task, nil
 }
 
+func (e *PersistentTaskEventStore) Delete(key string) error {
+	return e.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(e.Bucket))
+		return b.Delete([]byte(key))
+	})
+}
+
 func (e *PersistentTaskEventStore) List() ([]*task.TaskEvent, error) {
 	var events []*task.TaskEvent
 	err := e.Db.View(func(tx *bolt.Tx) error {
@@ -230,3 +244,110 @@ func (e *PersistentTaskEventStore) List() ([]*task.TaskEvent, error) {
 	}
 	return events, nil
 }
+
+// PersistentObjectStore is a store for values of any type
+// that have no dedicated store. Values are stored as JSON.
+type PersistentObjectStore[V any] struct {
+	Db       *bolt.DB
+	DbFile   string
+	FileMode os.FileMode
+	Bucket   string
+}
+
+func NewPersistentObjectStore[V any](file string, mode os.FileMode, bucket string) (*PersistentObjectStore[V], error) {
+	db, err := bolt.Open(file, mode, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to upen %v", file)
+	}
+	s := PersistentObjectStore[V]{
+		DbFile:   file,
+		FileMode: mode,
+		Db:       db,
+		Bucket:   bucket,
+	}
+	err = s.CreateBucket()
+	if err != nil {
+		log.Printf("[store.PersistentObjectStore] [NewPersistentObjectStore] bucket already exists, will use it instead of creating a new one")
+	}
+	return &s, nil
+}
+
+func (s *PersistentObjectStore[V]) Close() {
+	s.Db.Close()
+}
+
+func (s *PersistentObjectStore[V]) CreateBucket() error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte(s.Bucket))
+		if err != nil {
+			return fmt.Errorf("error creating bucket %s: %s", s.Bucket, err)
+		}
+		return nil
+	})
+}
+
+func (s *PersistentObjectStore[V]) Count() (int, error) {
+	count := 0
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		return b.ForEach(func(k, v []byte) error {
+			count++
+			return nil
+		})
+	})
+	if err != nil {
+		return -1, err
+	}
+	return count, nil
+}
+
+func (s *PersistentObjectStore[V]) Put(key string, v V) error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), buf)
+	})
+}
+
+func (s *PersistentObjectStore[V]) Get(key string) (V, error) {
+	var v V
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return fmt.Errorf("value with key %s does not exist", key)
+		}
+		return json.Unmarshal(raw, &v)
+	})
+	return v, err
+}
+
+func (s *PersistentObjectStore[V]) List() ([]V, error) {
+	var values []V
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		return b.ForEach(func(k, raw []byte) error {
+			var v V
+			err := json.Unmarshal(raw, &v)
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (s *PersistentObjectStore[V]) Delete(key string) error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		return b.Delete([]byte(key))
+	})
+}
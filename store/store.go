@@ -12,4 +12,5 @@ type Store[K comparable, V any] interface {
 	Get(key K) (V, error)
 	List() ([]V, error)
 	Count() (int, error)
+	Delete(key K) error
 }
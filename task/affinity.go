@@ -0,0 +1,203 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+type NodeSelectorOperator string
+
+const (
+	// In matches nodes with the label set to one of Values
+	In NodeSelectorOperator = "In"
+	// NotIn matches nodes without the label or
+	// with the label set to none of Values
+	NotIn NodeSelectorOperator = "NotIn"
+	// Exists matches nodes with the label set
+	Exists NodeSelectorOperator = "Exists"
+)
+
+// NodeSelectorRequirement matches
+// nodes by one of their labels.
+type NodeSelectorRequirement struct {
+	Key      string
+	Operator NodeSelectorOperator
+	Values   []string
+}
+
+// PreferredNodeAffinity is a requirement nodes should
+// meet. Weight (1-100) ranks it against other preferences.
+type PreferredNodeAffinity struct {
+	Weight int
+	NodeSelectorRequirement
+}
+
+// NodeAffinity constrains nodes a task is scheduled onto.
+type NodeAffinity struct {
+	// Required requirements are all met by every node
+	// the task is scheduled onto
+	Required []NodeSelectorRequirement
+	// Preferred requirements make nodes meeting
+	// them more likely to be chosen
+	Preferred []PreferredNodeAffinity
+}
+
+// TaskAntiAffinity keeps a task away
+// from tasks matching Selector.
+type TaskAntiAffinity struct {
+	// Selector lists labels of tasks to keep away from
+	Selector map[string]string
+	// TopologyKey is a node label, e.g. zone. The task is
+	// not placed on nodes sharing its value with a node
+	// running a matching task. Empty keeps the task off
+	// the very nodes running matching tasks.
+	TopologyKey string
+}
+
+// TopologySpreadConstraint spreads matching tasks evenly
+// over nodes grouped by the value of a node label.
+type TopologySpreadConstraint struct {
+	// TopologyKey is a node label, e.g. zone or rack.
+	// Nodes without it are not used. Empty spreads
+	// tasks over individual nodes.
+	TopologyKey string
+	// MaxSkew is the largest allowed difference between
+	// numbers of matching tasks in any two groups.
+	// Defaults to 1.
+	MaxSkew int
+	// Selector lists labels of tasks that are
+	// counted. Defaults to labels of the task.
+	Selector map[string]string
+}
+
+// Skew returns MaxSkew or its default.
+func (c TopologySpreadConstraint) Skew() int {
+	if c.MaxSkew <= 0 {
+		return 1
+	}
+	return c.MaxSkew
+}
+
+// MatchLabels checks whether labels contain
+// every key/value pair of the selector.
+func MatchLabels(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the requirement is well-formed.
+func (r NodeSelectorRequirement) Validate() error {
+	if r.Key == "" {
+		return errors.New("node selector requirement requires a key")
+	}
+	switch r.Operator {
+	case In, NotIn:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("operator %s of node selector requirement %q requires values", r.Operator, r.Key)
+		}
+	case Exists:
+		if len(r.Values) > 0 {
+			return fmt.Errorf("operator %s of node selector requirement %q does not take values", r.Operator, r.Key)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q of node selector requirement %q", r.Operator, r.Key)
+	}
+	return nil
+}
+
+// Matches checks whether a node with
+// the given labels meets the requirement.
+func (r NodeSelectorRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case In:
+		return ok && slices.Contains(r.Values, value)
+	case NotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	case Exists:
+		return ok
+	}
+	return false
+}
+
+// ValidatePlacement checks node affinity, anti-affinity,
+// topology spread constraints and tolerations of the task.
+func (t *Task) ValidatePlacement() error {
+	for _, a := range t.AntiAffinity {
+		if len(a.Selector) == 0 {
+			return errors.New("anti-affinity requires a selector")
+		}
+	}
+	for _, tol := range t.Tolerations {
+		if tol.Operator != "" && tol.Operator != Equal && tol.Operator != TolerationExists {
+			return fmt.Errorf("unknown toleration operator %q", tol.Operator)
+		}
+		if tol.Key == "" && tol.Operator != TolerationExists {
+			return errors.New("toleration without a key requires operator Exists")
+		}
+	}
+	for _, c := range t.TopologySpread {
+		if c.MaxSkew < 0 {
+			return errors.New("max skew of topology spread constraint cannot be negative")
+		}
+		if len(c.Selector) == 0 && len(t.Labels) == 0 {
+			return errors.New("topology spread constraint requires a selector or labels of the task")
+		}
+	}
+	if t.NodeAffinity == nil {
+		return nil
+	}
+	for _, r := range t.NodeAffinity.Required {
+		err := r.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range t.NodeAffinity.Preferred {
+		err := p.Validate()
+		if err != nil {
+			return err
+		}
+		if p.Weight < 1 || p.Weight > 100 {
+			return fmt.Errorf("weight of preferred node affinity %q has to be between 1 and 100", p.Key)
+		}
+	}
+	return nil
+}
+
+// MatchesNode checks whether a node with the given labels
+// meets the node selector and required node affinity.
+func (t *Task) MatchesNode(labels map[string]string) bool {
+	if !MatchLabels(t.NodeSelector, labels) {
+		return false
+	}
+	if t.NodeAffinity == nil {
+		return true
+	}
+	for _, r := range t.NodeAffinity.Required {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// NodePreference sums weights of preferred node
+// affinities a node with the given labels meets.
+func (t *Task) NodePreference(labels map[string]string) int {
+	if t.NodeAffinity == nil {
+		return 0
+	}
+	preference := 0
+	for _, p := range t.NodeAffinity.Preferred {
+		if p.Matches(labels) {
+			preference += p.Weight
+		}
+	}
+	return preference
+}
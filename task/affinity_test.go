@@ -0,0 +1,115 @@
+package task
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNodeSelectorRequirementMatches(t *testing.T) {
+	labels := map[string]string{"disk": "ssd", "zone": "a"}
+	tests := []struct {
+		r    NodeSelectorRequirement
+		want bool
+	}{
+		{NodeSelectorRequirement{Key: "disk", Operator: In, Values: []string{"hdd", "ssd"}}, true},
+		{NodeSelectorRequirement{Key: "disk", Operator: In, Values: []string{"hdd"}}, false},
+		{NodeSelectorRequirement{Key: "gpu", Operator: In, Values: []string{"yes"}}, false},
+		{NodeSelectorRequirement{Key: "zone", Operator: NotIn, Values: []string{"b"}}, true},
+		{NodeSelectorRequirement{Key: "zone", Operator: NotIn, Values: []string{"a"}}, false},
+		{NodeSelectorRequirement{Key: "gpu", Operator: NotIn, Values: []string{"yes"}}, true},
+		{NodeSelectorRequirement{Key: "disk", Operator: Exists}, true},
+		{NodeSelectorRequirement{Key: "gpu", Operator: Exists}, false},
+		{NodeSelectorRequirement{Key: "disk", Operator: "Gt", Values: []string{"1"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Matches(labels); got != tt.want {
+			t.Errorf("%s %s %v: got %v, want %v", tt.r.Key, tt.r.Operator, tt.r.Values, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesNode(t *testing.T) {
+	labels := map[string]string{"disk": "ssd", "zone": "a"}
+	tests := []struct {
+		name string
+		t    Task
+		want bool
+	}{
+		{"no constraints", Task{}, true},
+		{"selector", Task{NodeSelector: map[string]string{"disk": "ssd"}}, true},
+		{"selector value", Task{NodeSelector: map[string]string{"disk": "hdd"}}, false},
+		{"selector key", Task{NodeSelector: map[string]string{"gpu": "yes"}}, false},
+		{"required", Task{NodeAffinity: &NodeAffinity{Required: []NodeSelectorRequirement{
+			{Key: "zone", Operator: In, Values: []string{"a", "b"}},
+			{Key: "disk", Operator: Exists},
+		}}}, true},
+		{"one required unmet", Task{NodeAffinity: &NodeAffinity{Required: []NodeSelectorRequirement{
+			{Key: "zone", Operator: In, Values: []string{"a"}},
+			{Key: "gpu", Operator: Exists},
+		}}}, false},
+		{"preferred only", Task{NodeAffinity: &NodeAffinity{Preferred: []PreferredNodeAffinity{
+			{Weight: 10, NodeSelectorRequirement: NodeSelectorRequirement{Key: "gpu", Operator: Exists}},
+		}}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.t.MatchesNode(labels); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNodePreference(t *testing.T) {
+	tk := Task{NodeAffinity: &NodeAffinity{Preferred: []PreferredNodeAffinity{
+		{Weight: 30, NodeSelectorRequirement: NodeSelectorRequirement{Key: "disk", Operator: In, Values: []string{"ssd"}}},
+		{Weight: 20, NodeSelectorRequirement: NodeSelectorRequirement{Key: "zone", Operator: NotIn, Values: []string{"b"}}},
+	}}}
+	tests := []struct {
+		labels map[string]string
+		want   int
+	}{
+		{map[string]string{"disk": "ssd", "zone": "a"}, 50},
+		{map[string]string{"disk": "ssd", "zone": "b"}, 30},
+		{map[string]string{"disk": "hdd"}, 20},
+		{map[string]string{"disk": "hdd", "zone": "b"}, 0},
+	}
+	for _, tt := range tests {
+		if got := tk.NodePreference(tt.labels); got != tt.want {
+			t.Errorf("%v: got %d, want %d", tt.labels, got, tt.want)
+		}
+	}
+	if got := (&Task{}).NodePreference(map[string]string{"disk": "ssd"}); got != 0 {
+		t.Errorf("task without node affinity: got %d, want 0", got)
+	}
+}
+
+func TestValidateNodeAffinity(t *testing.T) {
+	preferred := func(weight int, r NodeSelectorRequirement) *NodeAffinity {
+		return &NodeAffinity{Preferred: []PreferredNodeAffinity{{Weight: weight, NodeSelectorRequirement: r}}}
+	}
+	tests := []struct {
+		name    string
+		a       *NodeAffinity
+		wantErr string
+	}{
+		{"none", nil, ""},
+		{"valid", &NodeAffinity{Required: []NodeSelectorRequirement{{Key: "zone", Operator: In, Values: []string{"a"}}}}, ""},
+		{"no key", &NodeAffinity{Required: []NodeSelectorRequirement{{Operator: Exists}}}, "requires a key"},
+		{"no values", &NodeAffinity{Required: []NodeSelectorRequirement{{Key: "zone", Operator: NotIn}}}, "requires values"},
+		{"values of exists", &NodeAffinity{Required: []NodeSelectorRequirement{{Key: "zone", Operator: Exists, Values: []string{"a"}}}}, "does not take values"},
+		{"unknown operator", &NodeAffinity{Required: []NodeSelectorRequirement{{Key: "zone", Operator: "Gt"}}}, "unknown operator"},
+		{"preferred", preferred(100, NodeSelectorRequirement{Key: "disk", Operator: Exists}), ""},
+		{"zero weight", preferred(0, NodeSelectorRequirement{Key: "disk", Operator: Exists}), "between 1 and 100"},
+		{"large weight", preferred(101, NodeSelectorRequirement{Key: "disk", Operator: Exists}), "between 1 and 100"},
+		{"invalid preferred", preferred(10, NodeSelectorRequirement{Key: "disk", Operator: In}), "requires values"},
+	}
+	for _, tt := range tests {
+		tk := Task{NodeAffinity: tt.a}
+		err := tk.ValidatePlacement()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package task
 
-import "github.com/docker/go-connections/nat"
+import (
+	"time"
+
+	"github.com/docker/go-connections/nat"
+)
 
 // Config struct defines a configuration of a task
 type Config struct {
@@ -10,8 +14,18 @@ type Config struct {
 	AttachStdout bool
 	AttachStderr bool
 	ExposedPorts nat.PortSet
+	// PortBindings maps container ports to host ports
+	PortBindings map[string]string
+	Entrypoint   []string
 	Cmd          []string
-	Image        string
+	// Args are appended to Cmd
+	Args  []string
+	Image string
+	// ImagePullPolicy defines when the image is pulled
+	ImagePullPolicy PullPolicy
+	// RegistryAuth is a name of registry
+	// credentials configured on the worker
+	RegistryAuth string
 	Cpu          float64
 	// Cpu and Memory used by scheduler to find a node in
 	// the cluster capable of running a task. They will also
@@ -21,8 +35,28 @@ type Config struct {
 	Disk   int64
 	// Env is used to specify ENV variables
 	Env []string
-	// Restart policy specifies what Docker daemon has to do
-	// if a container dies unexpectedly. Possible values are
-	// always, unless-stopped, on-failure, or no.
-	RestartPolicy string
+	// WorkingDir is the directory
+	// the command is started in
+	WorkingDir string
+	// User (name or uid[:gid]) the command is run as
+	User string
+	// Mounts lists storage mounted into the task.
+	// Every volume mount has a name.
+	Mounts []Mount
+	// Labels are attached to the instance
+	// of the task, e.g. a container
+	Labels map[string]string
+	// Sidecars are configurations of additional
+	// containers sharing the network namespace
+	// of the task's container
+	Sidecars []Config
+	// NetworkOf is the id of the instance whose
+	// network namespace the instance joins
+	NetworkOf string
+	// InitContainers are configurations of containers
+	// run to completion before the task is started
+	InitContainers []Config
+	// StopGracePeriod is the time the instance is
+	// given to exit after SIGTERM before it is killed
+	StopGracePeriod time.Duration
 }
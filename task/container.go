@@ -0,0 +1,124 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+)
+
+// Container describes an additional container of a task,
+// either a sidecar or an init container.
+//
+// Together with the task's own container sidecars form
+// a task group: they are started after the main container
+// in order, share its network namespace, so they can reach
+// each other on localhost, and are stopped and restarted
+// with it.
+//
+// Init containers are run one by one before the task is
+// started and each of them has to exit with code 0.
+type Container struct {
+	// Name identifies the container within the task
+	Name            string
+	Image           string
+	ImagePullPolicy PullPolicy
+	RegistryAuth    string
+	Entrypoint      []string
+	Cmd             []string
+	Args            []string
+	Env             []string
+	WorkingDir      string
+	User            string
+	Cpu             float64
+	Memory          int64
+	Disk            int64
+	// ExposedPorts of a sidecar are published by the task's
+	// container and get host ports like the task's own ports.
+	// They are ignored for init containers.
+	ExposedPorts nat.PortSet
+	// Mounts of the container. Volumes are shared
+	// with the main container by name.
+	Mounts []Mount
+	// TimeoutSeconds limits how long an init container may
+	// run before the task fails. Defaults to 10 minutes.
+	TimeoutSeconds int
+}
+
+// Timeout returns the time an init container may run.
+func (c *Container) Timeout() time.Duration {
+	if c.TimeoutSeconds == 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// validateSidecars checks that every sidecar
+// has a unique name and an image.
+func validateSidecars(c Config) error {
+	names := make(map[string]bool)
+	for _, s := range c.Sidecars {
+		name := s.Labels[LabelSidecar]
+		if name == "" {
+			return errors.New("sidecar requires a name")
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate sidecar %s", name)
+		}
+		names[name] = true
+		if s.Image == "" {
+			return fmt.Errorf("sidecar %s requires an image", name)
+		}
+	}
+	return nil
+}
+
+// sidecarConfig returns a configuration of a sidecar
+// of task t. Volumes without a source are anonymous and
+// private to the sidecar, named volumes are shared with
+// other containers mounting them.
+func sidecarConfig(t *Task, s Container) Config {
+	c := containerConfig(t, s)
+	c.Labels[LabelSidecar] = s.Name
+	return c
+}
+
+// initContainerConfig returns a configuration
+// of an init container of task t.
+func initContainerConfig(t *Task, s Container) Config {
+	c := containerConfig(t, s)
+	c.Labels[LabelInitContainer] = s.Name
+	if c.Name != "" {
+		c.Name = fmt.Sprintf("%s-init", c.Name)
+	}
+	return c
+}
+
+func containerConfig(t *Task, s Container) Config {
+	name := ""
+	if t.Name != "" {
+		name = fmt.Sprintf("%s-%s", t.Name, s.Name)
+	}
+	return Config{
+		Labels: map[string]string{
+			LabelTaskID:   t.ID.String(),
+			LabelTaskName: t.Name,
+		},
+		Mounts:          s.Mounts,
+		Name:            name,
+		Image:           s.Image,
+		ImagePullPolicy: s.ImagePullPolicy,
+		RegistryAuth:    s.RegistryAuth,
+		Entrypoint:      s.Entrypoint,
+		Cmd:             s.Cmd,
+		Args:            s.Args,
+		Env:             s.Env,
+		WorkingDir:      s.WorkingDir,
+		User:            s.User,
+		Cpu:             s.Cpu,
+		Memory:          s.Memory,
+		Disk:            s.Disk,
+		StopGracePeriod: t.StopGracePeriod(),
+	}
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent lets runs of a cron task overlap
+	AllowConcurrent ConcurrencyPolicy = "allow"
+	// ForbidConcurrent skips a run while the previous one is active
+	ForbidConcurrent ConcurrencyPolicy = "forbid"
+	// ReplaceConcurrent stops the active run before starting a new one
+	ReplaceConcurrent ConcurrencyPolicy = "replace"
+)
+
+// CronTask is a template of a task the
+// manager submits on a cron schedule.
+type CronTask struct {
+	ID   uuid.UUID
+	Name string
+	// Schedule is a cron expression with five fields
+	// (minute, hour, day of month, month and day of week)
+	// or one of @hourly, @daily, @weekly, @monthly, @yearly
+	Schedule string
+	// TimeZone is an IANA time zone the schedule is
+	// interpreted in. UTC is used when it is empty.
+	TimeZone string
+	// ConcurrencyPolicy defines what happens when a run is
+	// due while a task of a previous run is still active.
+	// Defaults to allow.
+	ConcurrencyPolicy ConcurrencyPolicy
+	// Suspended cron tasks are not run on schedule,
+	// but can still be triggered by hand
+	Suspended bool
+	// SuccessfulHistoryLimit and FailedHistoryLimit are the
+	// numbers of finished tasks kept, older tasks are deleted.
+	// They default to 3 and 1 when they are not set.
+	SuccessfulHistoryLimit *int
+	FailedHistoryLimit     *int
+	// Task is a template of submitted tasks. They
+	// are run as jobs whatever their Kind is.
+	Task             Task
+	CreationTime     time.Time
+	LastScheduleTime time.Time
+	// LastTriggerTime is the time the cron task was last
+	// run by hand. Manual runs do not affect the schedule.
+	LastTriggerTime time.Time
+	// Active lists tasks submitted by the
+	// cron task that have not finished yet
+	Active []uuid.UUID
+	// Successful and Failed list finished
+	// tasks, the oldest ones first
+	Successful []uuid.UUID
+	Failed     []uuid.UUID
+}
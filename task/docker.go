@@ -2,93 +2,175 @@ package task
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"math"
-	"os"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 )
 
-// Docker is a struct wrapping a pointer to
+// Docker is a Runtime driver wrapping a pointer to
 // Docker SDK client, used to interact with
-// the Docker API, and an instance of a task.Config
+// the Docker API.
 type Docker struct {
 	Client *client.Client
-	Config Config
+	// Auths stores registry credentials keyed
+	// by a registry host or a name tasks refer to
+	Auths map[string]registry.AuthConfig
 }
 
-// Pulls images, creates and runs containers
-func (d *Docker) Run() DockerResult {
+// Validate checks that c can be run as a container.
+func (d *Docker) Validate(c Config) error {
+	if c.Image == "" {
+		return errors.New("docker runtime requires an image")
+	}
+	err := d.validatePull(c)
+	if err != nil {
+		return err
+	}
+	err = validateSidecars(c)
+	if err != nil {
+		return err
+	}
+	for _, s := range c.Sidecars {
+		err := d.validatePull(s)
+		if err != nil {
+			return err
+		}
+		for _, m := range s.Mounts {
+			err := m.Validate()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, m := range c.Mounts {
+		err := m.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	_, _, err = portBindings(c)
+	return err
+}
+
+// Pulls images, creates and runs containers.
+// Sidecars are started by the worker.
+func (d *Docker) Run(c Config) RuntimeResult {
 	ctx := context.Background()
-	reader, err := d.Client.ImagePull(ctx, d.Config.Image, image.PullOptions{})
+	id, events, err := d.start(ctx, c)
 	if err != nil {
-		log.Printf("[task.Docker] [Run] Error pulling image %s: %v\n", d.Config.Image, err)
-		return DockerResult{Error: err}
+		return RuntimeResult{Error: err, Events: events}
 	}
-	io.Copy(os.Stdout, reader)
 
+	return RuntimeResult{
+		Action:      "start",
+		ContainerId: id,
+		Result:      "success",
+		Events:      events,
+	}
+}
+
+// start pulls the image, creates and starts a container.
+// Containers joining the network namespace of another
+// container (sidecars) do not publish ports.
+func (d *Docker) start(ctx context.Context, c Config) (string, []Event, error) {
+	events, err := d.pull(ctx, c)
+	if err != nil {
+		log.Printf("[task.Docker] [Run] Error pulling image %s: %v\n", c.Image, err)
+		return "", events, err
+	}
+
+	// Tasks are restarted by the manager according
+	// to their restart policies, never by Docker
 	restartPolicy := container.RestartPolicy{
-		Name: container.RestartPolicyMode(d.Config.RestartPolicy),
+		Name: container.RestartPolicyDisabled,
 	}
 
 	resources := container.Resources{
-		Memory:   d.Config.Memory,
-		NanoCPUs: int64(d.Config.Cpu * math.Pow(10, 9)),
+		Memory:   c.Memory,
+		NanoCPUs: int64(c.Cpu * math.Pow(10, 9)),
+	}
+
+	exposedPorts, bindings, err := portBindings(c)
+	if err != nil {
+		log.Printf("[task.Docker] [Run] Error parsing port bindings: %v\n", err)
+		return "", events, err
 	}
 
 	containerConfig := container.Config{
-		Image:        d.Config.Image,
+		Image:        c.Image,
 		Tty:          false,
-		Env:          d.Config.Env,
-		ExposedPorts: d.Config.ExposedPorts,
+		AttachStdin:  c.AttachStdin,
+		AttachStdout: c.AttachStdout,
+		AttachStderr: c.AttachStderr,
+		Env:          c.Env,
+		ExposedPorts: exposedPorts,
+		Entrypoint:   c.Entrypoint,
+		WorkingDir:   c.WorkingDir,
+		User:         c.User,
+		Labels:       c.Labels,
+	}
+	if c.StopGracePeriod > 0 {
+		timeout := int(c.StopGracePeriod.Seconds())
+		containerConfig.StopTimeout = &timeout
+	}
+	if len(c.Cmd) > 0 || len(c.Args) > 0 {
+		containerConfig.Cmd = append(append([]string{}, c.Cmd...), c.Args...)
 	}
 
+	var networkMode container.NetworkMode
+	if c.NetworkOf != "" {
+		networkMode = container.NetworkMode("container:" + c.NetworkOf)
+	}
+
+	// Ports without an explicit binding are
+	// published to random host ports.
 	hostConfig := container.HostConfig{
 		RestartPolicy:   restartPolicy,
 		Resources:       resources,
-		PublishAllPorts: true,
+		PortBindings:    bindings,
+		PublishAllPorts: networkMode == "",
+		NetworkMode:     networkMode,
+		Mounts:          mounts(c),
 	}
 
-	resp, err := d.Client.ContainerCreate(ctx, &containerConfig, &hostConfig, nil, nil, d.Config.Name)
+	resp, err := d.Client.ContainerCreate(ctx, &containerConfig, &hostConfig, nil, nil, c.Name)
 	if err != nil {
-		log.Printf("[task.Docker] [Run] Error creating container using image %s: %v\n", d.Config.Image, err)
-		return DockerResult{Error: err}
+		log.Printf("[task.Docker] [Run] Error creating container using image %s: %v\n", c.Image, err)
+		return "", events, err
 	}
 
 	err = d.Client.ContainerStart(ctx, resp.ID, container.StartOptions{})
 	if err != nil {
 		log.Printf("[task.Docker] [Run] Error starting container %s: %v\n", resp.ID, err)
-		return DockerResult{Error: err}
-	}
-
-	out, err := d.Client.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
-	if err != nil {
-		log.Printf("[task.Docker] [Run] Error getting logs from container %s: %v\n", resp.ID, err)
-		return DockerResult{Error: err}
-	}
-
-	stdcopy.StdCopy(os.Stdout, os.Stderr, out)
-
-	return DockerResult{
-		Action:      "start",
-		ContainerId: resp.ID,
-		Result:      "success",
+		return resp.ID, events, err
 	}
+	return resp.ID, events, nil
 }
 
-func (d *Docker) Stop(id string) DockerResult {
+// Stop stops and removes the container.
+func (d *Docker) Stop(id string) RuntimeResult {
 	log.Printf("[task.Docker] [Stop] Attempting to stop container %s", id)
 	ctx := context.Background()
 	err := d.Client.ContainerStop(ctx, id, container.StopOptions{})
 	if err != nil {
 		log.Printf("[task.Docker] [Stop] Error stopping container %s: %v\n", id, err)
-		return DockerResult{Error: err}
+		return RuntimeResult{Error: err}
 	}
 
+	// Only anonymous volumes are removed. Named volumes
+	// are kept to be reused when the task is restarted
+	// and have to be removed by hand.
 	err = d.Client.ContainerRemove(ctx, id, container.RemoveOptions{
 		RemoveVolumes: true,
 		RemoveLinks:   false,
@@ -96,47 +178,175 @@ func (d *Docker) Stop(id string) DockerResult {
 	})
 	if err != nil {
 		log.Printf("[task.Docker] [Stop] Error removing container %s: %v\n", id, err)
-		return DockerResult{Error: err}
+		return RuntimeResult{Error: err}
 	}
 
-	return DockerResult{
+	return RuntimeResult{
 		Action: "stop",
 		Result: "success",
 	}
 }
 
-func (d *Docker) Inspect(containerID string) DockerInspectResponse {
-	dc, _ := client.NewClientWithOpts(client.FromEnv)
+func (d *Docker) Inspect(containerID string) InspectResponse {
 	ctx := context.Background()
-	resp, err := dc.ContainerInspect(ctx, containerID)
+	resp, err := d.Client.ContainerInspect(ctx, containerID)
 	if err != nil {
-		log.Printf("[task.Docker] [Inspect] Error inspecting container: %x\n", containerID)
-		return DockerInspectResponse{Error: err}
+		log.Printf("[task.Docker] [Inspect] Error inspecting container %s: %v\n", containerID, err)
+		return InspectResponse{Error: err}
+	}
+	result := InspectResponse{
+		Status:   resp.State.Status,
+		ExitCode: resp.State.ExitCode,
 	}
-	return DockerInspectResponse{Container: &resp}
+	if resp.NetworkSettings != nil {
+		result.HostPorts = resp.NetworkSettings.Ports
+	}
+	return result
 }
 
-func NewDocker(c *Config) *Docker {
-	d, _ := client.NewClientWithOpts(client.FromEnv)
-	return &Docker{
-		Client: d,
-		Config: *c,
+// Logs copies container logs to stdout and stderr.
+// Docker multiplexes both streams into one
+// when container has no TTY, so they are
+// demultiplexed with stdcopy.
+func (d *Docker) Logs(ctx context.Context, containerID string, opts LogsOptions, stdout, stderr io.Writer) error {
+	out, err := d.Client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: opts.Stdout,
+		ShowStderr: opts.Stderr,
+		Follow:     opts.Follow,
+		Tail:       opts.Tail,
+		Since:      opts.Since,
+	})
+	if err != nil {
+		log.Printf("[task.Docker] [Logs] Error getting logs from container %s: %v\n", containerID, err)
+		return err
+	}
+	defer out.Close()
+	_, err = stdcopy.StdCopy(stdout, stderr, out)
+	return err
+}
+
+// mounts converts mounts of c into Docker's format.
+func mounts(c Config) []mount.Mount {
+	var result []mount.Mount
+	for _, m := range c.Mounts {
+		dm := mount.Mount{
+			Type:     mount.Type(m.Type),
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		}
+		if m.Type == TmpfsMount {
+			dm.Source = ""
+			dm.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: m.SizeBytes}
+		}
+		result = append(result, dm)
+	}
+	return result
+}
+
+// portBindings converts port bindings of c into
+// Docker's format. Bound ports are exposed even if
+// they are missing from c.ExposedPorts.
+func portBindings(c Config) (nat.PortSet, nat.PortMap, error) {
+	exposed := nat.PortSet{}
+	for port := range c.ExposedPorts {
+		exposed[port] = struct{}{}
 	}
+	bindings := nat.PortMap{}
+	for containerPort, hostPort := range c.PortBindings {
+		port, err := nat.NewPort(nat.SplitProtoPort(containerPort))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid container port %q: %v", containerPort, err)
+		}
+		_, err = nat.ParsePort(hostPort)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid host port %q for %s: %v", hostPort, containerPort, err)
+		}
+		exposed[port] = struct{}{}
+		bindings[port] = []nat.PortBinding{{HostPort: hostPort}}
+	}
+	return exposed, bindings, nil
 }
 
-// DockerResult is a convenience struct.
-// It is used as a return value in methods
-// that start or stop containers, providing a
-// wrapper around common information that is
-// useful for callers.
-//
-// Action field specifies the action being taken, e.g. start or stop.
-// ContainerId specifies the container to which the result belongs.
-// Result field can hold arbitrary text that provides more information
-// about the result of the operation.
-type DockerResult struct {
-	Error       error
-	Action      string
-	ContainerId string
-	Result      string
+// Exec runs a command in a running container
+// using Docker exec.
+func (d *Docker) Exec(ctx context.Context, containerID string, opts ExecOptions, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	execConfig := types.ExecConfig{
+		Cmd:          opts.Cmd,
+		Tty:          opts.Tty,
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	created, err := d.Client.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		log.Printf("[task.Docker] [Exec] Error creating exec in container %s: %v\n", containerID, err)
+		return -1, err
+	}
+	hijacked, err := d.Client.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{Tty: opts.Tty})
+	if err != nil {
+		log.Printf("[task.Docker] [Exec] Error attaching to exec %s: %v\n", created.ID, err)
+		return -1, err
+	}
+	defer hijacked.Close()
+	// Closing the connection unblocks reading the output
+	stop := context.AfterFunc(ctx, hijacked.Close)
+	defer stop()
+	if stdin != nil {
+		go func() {
+			io.Copy(hijacked.Conn, stdin)
+			hijacked.CloseWrite()
+		}()
+	}
+	// Output is only multiplexed when there is no TTY
+	if opts.Tty {
+		_, err = io.Copy(stdout, hijacked.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, hijacked.Reader)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		log.Printf("[task.Docker] [Exec] Error copying output of exec %s: %v\n", created.ID, err)
+		return -1, err
+	}
+	inspect, err := d.Client.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		log.Printf("[task.Docker] [Exec] Error inspecting exec %s: %v\n", created.ID, err)
+		return -1, err
+	}
+	return inspect.ExitCode, nil
+}
+
+// List returns all containers labelled with a task ID.
+func (d *Docker) List() ([]Instance, error) {
+	ctx := context.Background()
+	containers, err := d.Client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", LabelTaskID)),
+	})
+	if err != nil {
+		log.Printf("[task.Docker] [List] Error listing containers: %v\n", err)
+		return nil, err
+	}
+	var instances []Instance
+	for _, c := range containers {
+		instances = append(instances, Instance{
+			ID:     c.ID,
+			Labels: c.Labels,
+			Status: c.State,
+		})
+	}
+	return instances, nil
+}
+
+func NewDocker() (*Docker, error) {
+	d, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, err
+	}
+	return &Docker{
+		Client: d,
+	}, nil
 }
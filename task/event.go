@@ -17,3 +17,35 @@ type TaskEvent struct {
 	Timestamp time.Time
 	Task      Task
 }
+
+// Event is a record of something that has
+// happened to a task, e.g. its image being pulled.
+type Event struct {
+	Timestamp time.Time
+	// Reason is a short machine-readable
+	// description of the event, e.g. Pulled
+	Reason  string
+	Message string
+}
+
+// NewEvent creates an event that happens now.
+func NewEvent(reason, message string) Event {
+	return Event{
+		Timestamp: time.Now().UTC(),
+		Reason:    reason,
+		Message:   message,
+	}
+}
+
+// maxEvents is the number of most
+// recent events kept on a task.
+const maxEvents = 50
+
+// AddEvents appends events to the task,
+// dropping the oldest ones if there are too many.
+func (t *Task) AddEvents(events ...Event) {
+	t.Events = append(t.Events, events...)
+	if len(t.Events) > maxEvents {
+		t.Events = t.Events[len(t.Events)-maxEvents:]
+	}
+}
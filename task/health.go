@@ -0,0 +1,226 @@
+package task
+
+import (
+	"errors"
+	"time"
+)
+
+// Probe describes a check of a running task. Exactly one
+// of HTTP, TCP, Exec and GRPC has to be set.
+type Probe struct {
+	HTTP *HTTPProbe
+	TCP  *TCPProbe
+	Exec *ExecProbe
+	GRPC *GRPCProbe
+	// InitialDelaySeconds is the time after the
+	// start of the task before the first check
+	InitialDelaySeconds int
+	// PeriodSeconds is the interval between checks.
+	// Defaults to 10.
+	PeriodSeconds int
+	// TimeoutSeconds limits a single check. Defaults to 1.
+	TimeoutSeconds int
+	// SuccessThreshold is the number of consecutive successful
+	// checks after which the task is considered healthy again.
+	// Defaults to 1.
+	SuccessThreshold int
+	// FailureThreshold is the number of consecutive failed
+	// checks after which the task is considered unhealthy.
+	// Defaults to 3.
+	FailureThreshold int
+}
+
+// HTTPProbe sends an HTTP request to the task.
+type HTTPProbe struct {
+	Path string
+	// Port is a name from NamedPorts or a container port.
+	// The first published port is used when it is empty.
+	Port string
+	// Method defaults to GET
+	Method  string
+	Headers map[string]string
+	// ExpectedStatus lists status codes of a healthy
+	// task. Any code from 200 to 399 is accepted
+	// when it is empty.
+	ExpectedStatus []int
+}
+
+// TCPProbe succeeds if a TCP connection
+// to the port can be opened.
+type TCPProbe struct {
+	Port string
+}
+
+// ExecProbe runs a command inside the task.
+// It succeeds when the command exits with code 0.
+type ExecProbe struct {
+	Command []string
+}
+
+// GRPCProbe calls the standard gRPC health checking
+// service (grpc.health.v1.Health/Check) of the task.
+// It succeeds when the service is SERVING.
+type GRPCProbe struct {
+	Port string
+	// Service is the name of the checked service.
+	// The server as a whole is checked when it is empty.
+	Service string
+}
+
+// Validate checks that the probe is well-formed.
+func (p *Probe) Validate() error {
+	kinds := 0
+	if p.HTTP != nil {
+		kinds++
+	}
+	if p.TCP != nil {
+		kinds++
+		if p.TCP.Port == "" {
+			return errors.New("TCP probe requires a port")
+		}
+	}
+	if p.Exec != nil {
+		kinds++
+		if len(p.Exec.Command) == 0 {
+			return errors.New("exec probe requires a command")
+		}
+	}
+	if p.GRPC != nil {
+		kinds++
+		if p.GRPC.Port == "" {
+			return errors.New("gRPC probe requires a port")
+		}
+	}
+	if kinds != 1 {
+		return errors.New("probe requires exactly one of HTTP, TCP, Exec and GRPC")
+	}
+	if p.InitialDelaySeconds < 0 || p.PeriodSeconds < 0 || p.TimeoutSeconds < 0 ||
+		p.SuccessThreshold < 0 || p.FailureThreshold < 0 {
+		return errors.New("probe timings and thresholds cannot be negative")
+	}
+	return nil
+}
+
+func (p *Probe) InitialDelay() time.Duration {
+	return time.Duration(p.InitialDelaySeconds) * time.Second
+}
+
+func (p *Probe) Period() time.Duration {
+	if p.PeriodSeconds == 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(p.PeriodSeconds) * time.Second
+}
+
+func (p *Probe) Timeout() time.Duration {
+	if p.TimeoutSeconds == 0 {
+		return time.Second
+	}
+	return time.Duration(p.TimeoutSeconds) * time.Second
+}
+
+func (p *Probe) Successes() int {
+	if p.SuccessThreshold == 0 {
+		return 1
+	}
+	return p.SuccessThreshold
+}
+
+func (p *Probe) Failures() int {
+	if p.FailureThreshold == 0 {
+		return 3
+	}
+	return p.FailureThreshold
+}
+
+// Probe returns the health probe of the task. Tasks with
+// only a HealthCheck path get an HTTP probe checking it
+// once a minute, which restarts them on the first failure.
+func (t *Task) Probe() *Probe {
+	if t.HealthProbe != nil {
+		return t.HealthProbe
+	}
+	if t.HealthCheck == "" {
+		return nil
+	}
+	return &Probe{
+		HTTP: &HTTPProbe{
+			Path: t.HealthCheck,
+			Port: t.HealthCheckPort,
+		},
+		PeriodSeconds:    60,
+		TimeoutSeconds:   10,
+		FailureThreshold: 1,
+	}
+}
+
+// IsReady checks whether the task is running and passes its
+// readiness probe. Tasks with a readiness probe start not ready
+// and become ready after SuccessThreshold consecutive successes.
+func (t *Task) IsReady() bool {
+	return t.State == Running && (t.ReadinessProbe == nil || t.Readiness.Status == Healthy)
+}
+
+type HealthStatus string
+
+const (
+	HealthUnknown HealthStatus = "unknown"
+	Healthy       HealthStatus = "healthy"
+	Unhealthy     HealthStatus = "unhealthy"
+)
+
+// maxHealthHistory is the number of
+// most recent probe results kept.
+const maxHealthHistory = 10
+
+// Health is the outcome of health checks of a task.
+type Health struct {
+	Status               HealthStatus
+	ConsecutiveSuccesses int
+	ConsecutiveFailures  int
+	LastCheck            time.Time
+	// History lists the most recent
+	// results, the oldest ones first
+	History []ProbeResult
+}
+
+// ProbeResult is the result of a single check.
+type ProbeResult struct {
+	Time     time.Time
+	Success  bool
+	Duration time.Duration
+	// Message explains why the check has failed
+	Message string
+}
+
+// Record adds the result of a check of probe p
+// and updates the status according to its thresholds.
+func (h *Health) Record(p *Probe, r ProbeResult) {
+	h.LastCheck = r.Time
+	h.History = append(h.History, r)
+	if len(h.History) > maxHealthHistory {
+		h.History = h.History[len(h.History)-maxHealthHistory:]
+	}
+	if r.Success {
+		h.ConsecutiveSuccesses++
+		h.ConsecutiveFailures = 0
+		if h.ConsecutiveSuccesses >= p.Successes() {
+			h.Status = Healthy
+		}
+		return
+	}
+	h.ConsecutiveFailures++
+	h.ConsecutiveSuccesses = 0
+	if h.ConsecutiveFailures >= p.Failures() {
+		h.Status = Unhealthy
+	}
+}
+
+// Reset forgets the status of a task being
+// restarted. History is kept.
+func (h *Health) Reset() {
+	h.Status = HealthUnknown
+	h.ConsecutiveSuccesses = 0
+	h.ConsecutiveFailures = 0
+	h.LastCheck = time.Time{}
+}
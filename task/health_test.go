@@ -0,0 +1,56 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadinessThreshold(t *testing.T) {
+	tk := Task{
+		State: Running,
+		ReadinessProbe: &Probe{
+			TCP:              &TCPProbe{Port: "80/tcp"},
+			SuccessThreshold: 3,
+			FailureThreshold: 2,
+		},
+	}
+	probe := tk.ReadinessProbe
+	steps := []struct {
+		success bool
+		ready   bool
+	}{
+		{true, false},
+		{true, false},
+		{true, true},
+		{false, true},
+		{false, false},
+		{true, false},
+		{true, false},
+		{true, true},
+	}
+	if tk.IsReady() {
+		t.Fatal("task is ready before its readiness probe has passed")
+	}
+	for i, step := range steps {
+		tk.Readiness.Record(probe, ProbeResult{Time: time.Now(), Success: step.success})
+		if tk.IsReady() != step.ready {
+			t.Fatalf("check %d: ready = %v, want %v", i+1, tk.IsReady(), step.ready)
+		}
+	}
+
+	tk.Readiness.Reset()
+	if tk.IsReady() {
+		t.Fatal("task is ready after its readiness has been reset")
+	}
+}
+
+func TestReadinessWithoutProbe(t *testing.T) {
+	tk := Task{State: Running}
+	if !tk.IsReady() {
+		t.Fatal("running task without a readiness probe is not ready")
+	}
+	tk.State = Completed
+	if tk.IsReady() {
+		t.Fatal("completed task is ready")
+	}
+}
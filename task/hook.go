@@ -0,0 +1,58 @@
+package task
+
+import (
+	"errors"
+	"time"
+)
+
+// defaultHookTimeout limits hooks without a timeout.
+const defaultHookTimeout = 30 * time.Second
+
+// Hook is an action the worker runs after a task
+// is started or before it is stopped. Exactly one
+// of Exec and HTTPGet has to be set.
+type Hook struct {
+	// Exec is a command run inside the task.
+	// It succeeds when it exits with code 0.
+	Exec []string
+	// HTTPGet sends a GET request to the task. It
+	// succeeds on status codes from 200 to 399.
+	HTTPGet *HTTPGetAction
+	// TimeoutSeconds defaults to 30
+	TimeoutSeconds int
+	// FailTask makes a failed hook fail the task.
+	// Otherwise the failure is only recorded
+	// in events of the task.
+	FailTask bool
+}
+
+// HTTPGetAction is a request sent to a task.
+type HTTPGetAction struct {
+	Path string
+	// Port is a name from NamedPorts or a container
+	// port. For tasks without published ports it is
+	// a port on the worker's machine.
+	Port string
+}
+
+// Validate checks that the hook is well-formed.
+func (h *Hook) Validate() error {
+	if (len(h.Exec) > 0) == (h.HTTPGet != nil) {
+		return errors.New("hook requires either Exec or HTTPGet")
+	}
+	if h.HTTPGet != nil && h.HTTPGet.Port == "" {
+		return errors.New("HTTPGet hook requires a port")
+	}
+	if h.TimeoutSeconds < 0 {
+		return errors.New("hook timeout cannot be negative")
+	}
+	return nil
+}
+
+// Timeout returns the time the hook is allowed to run.
+func (h *Hook) Timeout() time.Duration {
+	if h.TimeoutSeconds == 0 {
+		return defaultHookTimeout
+	}
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
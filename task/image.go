@@ -0,0 +1,161 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+type PullPolicy string
+
+const (
+	PullAlways       PullPolicy = "Always"
+	PullIfNotPresent PullPolicy = "IfNotPresent"
+	PullNever        PullPolicy = "Never"
+)
+
+// LoadRegistryAuths reads registry credentials from a JSON file
+// mapping either a registry host (e.g. registry.example.com) or
+// a name tasks refer to in RegistryAuth to credentials:
+//
+//	{"registry.example.com": {"username": "user", "password": "secret"}}
+func LoadRegistryAuths(filename string) (map[string]registry.AuthConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read registry credentials from %s: %v", filename, err)
+	}
+	auths := make(map[string]registry.AuthConfig)
+	err = json.Unmarshal(data, &auths)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry credentials from %s: %v", filename, err)
+	}
+	return auths, nil
+}
+
+// pullPolicy returns the pull policy of c. Images tagged
+// latest (or untagged) are always pulled by default,
+// any other image is only pulled if it is missing.
+func pullPolicy(c Config) PullPolicy {
+	if c.ImagePullPolicy != "" {
+		return c.ImagePullPolicy
+	}
+	named, err := reference.ParseNormalizedNamed(c.Image)
+	if err != nil {
+		return PullAlways
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return PullIfNotPresent
+	}
+	if tagged, ok := named.(reference.Tagged); ok && tagged.Tag() != "latest" {
+		return PullIfNotPresent
+	}
+	return PullAlways
+}
+
+// validatePull checks the pull policy and
+// registry credentials referenced by c.
+func (d *Docker) validatePull(c Config) error {
+	switch c.ImagePullPolicy {
+	case "", PullAlways, PullIfNotPresent, PullNever:
+	default:
+		return fmt.Errorf("unknown image pull policy %q", c.ImagePullPolicy)
+	}
+	if _, err := reference.ParseNormalizedNamed(c.Image); err != nil {
+		return fmt.Errorf("invalid image %q: %v", c.Image, err)
+	}
+	if c.RegistryAuth != "" {
+		if _, ok := d.Auths[c.RegistryAuth]; !ok {
+			return fmt.Errorf("registry credentials %q are not configured on the worker", c.RegistryAuth)
+		}
+	}
+	return nil
+}
+
+// registryAuth returns encoded credentials for the registry of c.Image.
+// Credentials referenced by c.RegistryAuth take precedence over
+// the ones configured for the registry host.
+func (d *Docker) registryAuth(c Config) (string, error) {
+	auth, ok := d.Auths[c.RegistryAuth]
+	if c.RegistryAuth == "" {
+		named, err := reference.ParseNormalizedNamed(c.Image)
+		if err != nil {
+			return "", err
+		}
+		auth, ok = d.Auths[reference.Domain(named)]
+	}
+	if !ok {
+		return "", nil
+	}
+	return registry.EncodeAuthConfig(auth)
+}
+
+// pull makes sure the image of c is present on the
+// machine according to its pull policy. Pull progress is
+// summarized in events instead of being printed.
+func (d *Docker) pull(ctx context.Context, c Config) ([]Event, error) {
+	var events []Event
+	policy := pullPolicy(c)
+	if policy != PullAlways {
+		_, _, err := d.Client.ImageInspectWithRaw(ctx, c.Image)
+		if err == nil {
+			events = append(events, NewEvent("Pulled", fmt.Sprintf("Image %s is already present on the machine", c.Image)))
+			return events, nil
+		}
+		if policy == PullNever {
+			events = append(events, NewEvent("ErrImageNeverPull", fmt.Sprintf("Image %s is not present and pull policy is Never", c.Image)))
+			return events, fmt.Errorf("image %s is not present and pull policy is %s", c.Image, PullNever)
+		}
+	}
+
+	auth, err := d.registryAuth(c)
+	if err != nil {
+		return events, err
+	}
+	events = append(events, NewEvent("Pulling", fmt.Sprintf("Pulling image %s", c.Image)))
+	reader, err := d.Client.ImagePull(ctx, c.Image, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		events = append(events, NewEvent("Failed", fmt.Sprintf("Failed to pull image %s: %v", c.Image, err)))
+		return events, err
+	}
+	defer reader.Close()
+
+	var layers int
+	var status []string
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		err := decoder.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			events = append(events, NewEvent("Failed", fmt.Sprintf("Failed to read pull progress of %s: %v", c.Image, err)))
+			return events, err
+		}
+		if msg.Error != nil {
+			events = append(events, NewEvent("Failed", fmt.Sprintf("Failed to pull image %s: %s", c.Image, msg.Error.Message)))
+			return events, msg.Error
+		}
+		switch {
+		case msg.Status == "Pull complete":
+			layers++
+		case strings.HasPrefix(msg.Status, "Digest:"), strings.HasPrefix(msg.Status, "Status:"):
+			status = append(status, msg.Status)
+		}
+	}
+	message := fmt.Sprintf("Pulled image %s (%d layers downloaded)", c.Image, layers)
+	if len(status) > 0 {
+		message = fmt.Sprintf("%s: %s", message, strings.Join(status, ", "))
+	}
+	events = append(events, NewEvent("Pulled", message))
+	return events, nil
+}
@@ -0,0 +1,47 @@
+package task
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+type MountType string
+
+const (
+	VolumeMount MountType = "volume"
+	BindMount   MountType = "bind"
+	TmpfsMount  MountType = "tmpfs"
+)
+
+// Mount describes storage mounted into a task.
+type Mount struct {
+	Type MountType
+	// Source is a name of a volume for volume
+	// mounts or a path on the host for bind mounts.
+	// It is not used by tmpfs mounts. Volume mounts
+	// without it get an anonymous volume, which is
+	// removed together with the task's container.
+	Source string
+	// Target is a path inside the container
+	Target   string
+	ReadOnly bool
+	// SizeBytes limits the size of a tmpfs mount
+	SizeBytes int64
+}
+
+// Validate checks that the mount is well-formed.
+func (m Mount) Validate() error {
+	if !filepath.IsAbs(m.Target) {
+		return fmt.Errorf("mount target %q must be an absolute path", m.Target)
+	}
+	switch m.Type {
+	case VolumeMount, TmpfsMount:
+	case BindMount:
+		if !filepath.IsAbs(m.Source) {
+			return fmt.Errorf("bind mount source %q must be an absolute path", m.Source)
+		}
+	default:
+		return fmt.Errorf("unknown mount type %q", m.Type)
+	}
+	return nil
+}
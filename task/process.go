@@ -0,0 +1,336 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Process is a Runtime driver that launches
+// a task's Cmd as a plain child process of the worker.
+// Every process gets its own process group, so
+// stopping a task also stops everything it has spawned.
+// Processes are only tracked in memory, so they do not
+// survive a restart of the worker: their tasks are
+// reported as failed and the processes are left running.
+type Process struct {
+	// LogDir is a directory where stdout and
+	// stderr of every process are stored
+	LogDir string
+	mu     sync.Mutex
+	procs  map[string]*process
+}
+
+// process tracks a single child process
+// started by the Process runtime.
+type process struct {
+	cmd    *exec.Cmd
+	stdout string
+	stderr string
+	// done is closed once the process has exited
+	done     chan struct{}
+	exitCode int
+	// stopTimeout is the time the process is given
+	// to exit after SIGTERM before it is killed
+	stopTimeout time.Duration
+}
+
+func NewProcess(logDir string) *Process {
+	return &Process{
+		LogDir: logDir,
+		procs:  make(map[string]*process),
+	}
+}
+
+// Validate rejects fields that only make sense for
+// containers, as well as resource limits and users
+// the process runtime has no means to enforce.
+func (p *Process) Validate(c Config) error {
+	var unsupported []string
+	if c.Image != "" {
+		unsupported = append(unsupported, "Image")
+	}
+	if c.ImagePullPolicy != "" {
+		unsupported = append(unsupported, "ImagePullPolicy")
+	}
+	if c.RegistryAuth != "" {
+		unsupported = append(unsupported, "RegistryAuth")
+	}
+	if c.User != "" {
+		unsupported = append(unsupported, "User")
+	}
+	if c.Cpu != 0 {
+		unsupported = append(unsupported, "Cpu")
+	}
+	if c.Memory != 0 {
+		unsupported = append(unsupported, "Memory")
+	}
+	if c.Disk != 0 {
+		unsupported = append(unsupported, "Disk")
+	}
+	if len(c.ExposedPorts) > 0 {
+		unsupported = append(unsupported, "ExposedPorts")
+	}
+	if len(c.PortBindings) > 0 {
+		unsupported = append(unsupported, "PortBindings")
+	}
+	if len(c.Mounts) > 0 {
+		unsupported = append(unsupported, "Mounts")
+	}
+	if len(c.Sidecars) > 0 {
+		unsupported = append(unsupported, "Sidecars")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("process runtime does not support %s", strings.Join(unsupported, ", "))
+	}
+	if len(c.Entrypoint) == 0 && len(c.Cmd) == 0 {
+		return errors.New("process runtime requires Entrypoint or Cmd to be set")
+	}
+	return nil
+}
+
+// Run starts the command built from c.Entrypoint, c.Cmd
+// and c.Args in a new process group with c.Env and
+// c.WorkingDir. The environment of the worker is not
+// inherited. Process id is used as an identifier
+// of the started instance.
+func (p *Process) Run(c Config) RuntimeResult {
+	err := p.Validate(c)
+	if err != nil {
+		log.Printf("[task.Process] [Run] Error starting task %s: %v\n", c.Name, err)
+		return RuntimeResult{Error: err}
+	}
+	argv := append(append(append([]string{}, c.Entrypoint...), c.Cmd...), c.Args...)
+	err = os.MkdirAll(p.LogDir, 0700)
+	if err != nil {
+		log.Printf("[task.Process] [Run] Error creating log directory %s: %v\n", p.LogDir, err)
+		return RuntimeResult{Error: err}
+	}
+	stdout, err := os.CreateTemp(p.LogDir, fmt.Sprintf("%s-*.stdout", c.Name))
+	if err != nil {
+		log.Printf("[task.Process] [Run] Error creating stdout file for task %s: %v\n", c.Name, err)
+		return RuntimeResult{Error: err}
+	}
+	stderr, err := os.CreateTemp(p.LogDir, fmt.Sprintf("%s-*.stderr", c.Name))
+	if err != nil {
+		stdout.Close()
+		log.Printf("[task.Process] [Run] Error creating stderr file for task %s: %v\n", c.Name, err)
+		return RuntimeResult{Error: err}
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	// A nil Env would pass the worker's own environment on
+	cmd.Env = append([]string{}, c.Env...)
+	cmd.Dir = c.WorkingDir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err = cmd.Start()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		log.Printf("[task.Process] [Run] Error starting process for task %s: %v\n", c.Name, err)
+		return RuntimeResult{Error: err}
+	}
+
+	proc := &process{
+		cmd:         cmd,
+		stdout:      stdout.Name(),
+		stderr:      stderr.Name(),
+		done:        make(chan struct{}),
+		stopTimeout: DefaultStopGracePeriod,
+	}
+	if c.StopGracePeriod > 0 {
+		proc.stopTimeout = c.StopGracePeriod
+	}
+	go func() {
+		cmd.Wait()
+		stdout.Close()
+		stderr.Close()
+		proc.exitCode = cmd.ProcessState.ExitCode()
+		close(proc.done)
+	}()
+
+	id := strconv.Itoa(cmd.Process.Pid)
+	p.mu.Lock()
+	p.procs[id] = proc
+	p.mu.Unlock()
+
+	return RuntimeResult{
+		Action:      "start",
+		ContainerId: id,
+		Result:      "success",
+	}
+}
+
+// Stop sends SIGTERM to the process group of the process
+// and returns without waiting for it to exit. The group is
+// killed if any of its processes does not exit in time,
+// and the output of the process is removed afterwards.
+func (p *Process) Stop(id string) RuntimeResult {
+	log.Printf("[task.Process] [Stop] Attempting to stop process %s", id)
+	proc, err := p.get(id)
+	if err != nil {
+		log.Printf("[task.Process] [Stop] Error stopping process %s: %v\n", id, err)
+		return RuntimeResult{Error: err}
+	}
+	p.mu.Lock()
+	delete(p.procs, id)
+	p.mu.Unlock()
+	// Processes the command has spawned may outlive it,
+	// so the group is signalled even if it has exited
+	pgid := -proc.cmd.Process.Pid
+	syscall.Kill(pgid, syscall.SIGTERM)
+	go func() {
+		if !waitGroup(pgid, proc.stopTimeout) {
+			log.Printf("[task.Process] [Stop] Process group of %s did not exit in %v, killing it\n", id, proc.stopTimeout)
+			syscall.Kill(pgid, syscall.SIGKILL)
+		}
+		<-proc.done
+		os.Remove(proc.stdout)
+		os.Remove(proc.stderr)
+	}()
+
+	return RuntimeResult{
+		Action: "stop",
+		Result: "success",
+	}
+}
+
+// waitGroup waits for all processes of the group to exit
+// and reports whether they have exited within the timeout.
+func waitGroup(pgid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if syscall.Kill(pgid, 0) == syscall.ESRCH {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func (p *Process) Inspect(id string) InspectResponse {
+	proc, err := p.get(id)
+	if err != nil {
+		log.Printf("[task.Process] [Inspect] Error inspecting process %s: %v\n", id, err)
+		return InspectResponse{Error: err}
+	}
+	select {
+	case <-proc.done:
+		return InspectResponse{Status: "exited", ExitCode: proc.exitCode}
+	default:
+		return InspectResponse{Status: "running"}
+	}
+}
+
+// Logs copies captured output of the process.
+// Process output is stored without timestamps,
+// so opts.Since is not supported.
+func (p *Process) Logs(ctx context.Context, id string, opts LogsOptions, stdout, stderr io.Writer) error {
+	if opts.Since != "" {
+		return fmt.Errorf("%w: process runtime does not support filtering logs by time", ErrUnsupportedLogsOption)
+	}
+	proc, err := p.get(id)
+	if err != nil {
+		return err
+	}
+	type stream struct {
+		f *os.File
+		w io.Writer
+	}
+	var streams []stream
+	if opts.Stdout {
+		streams = append(streams, stream{w: stdout})
+		streams[len(streams)-1].f, err = openTail(proc.stdout, opts.Tail)
+	}
+	if err == nil && opts.Stderr {
+		streams = append(streams, stream{w: stderr})
+		streams[len(streams)-1].f, err = openTail(proc.stderr, opts.Tail)
+	}
+	defer func() {
+		for _, s := range streams {
+			if s.f != nil {
+				s.f.Close()
+			}
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	for {
+		for _, s := range streams {
+			_, err := io.Copy(s.w, s.f)
+			if err != nil {
+				return err
+			}
+		}
+		if !opts.Follow {
+			return nil
+		}
+		select {
+		case <-proc.done:
+			// Process has exited, so whatever it has written
+			// is copied one last time before returning.
+			opts.Follow = false
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (p *Process) get(id string) (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	proc, ok := p.procs[id]
+	if !ok {
+		return nil, fmt.Errorf("process %s does not exist", id)
+	}
+	return proc, nil
+}
+
+// openTail opens the file and positions it
+// before the last tail lines. Whole file is read
+// when tail is empty, "all" or not a number.
+func openTail(name, tail string) (*os.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(tail)
+	if err != nil || n < 0 {
+		return f, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	offset := len(data)
+	if n > 0 {
+		// Trailing newline does not start a new line
+		end := bytes.TrimSuffix(data, []byte("\n"))
+		offset = 0
+		for i := len(end); n > 0 && i >= 0; n-- {
+			i = bytes.LastIndexByte(end[:i], '\n')
+			offset = i + 1
+		}
+	}
+	_, err = f.Seek(int64(offset), io.SeekStart)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,118 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       Config
+		wantErr string
+	}{
+		{"command", Config{Cmd: []string{"true"}}, ""},
+		{"entrypoint", Config{Entrypoint: []string{"true"}}, ""},
+		{"no command", Config{}, "requires Entrypoint or Cmd"},
+		{"image", Config{Image: "alpine", Cmd: []string{"true"}}, "Image"},
+		{"memory", Config{Memory: 1024, Cmd: []string{"true"}}, "Memory"},
+		{"port bindings", Config{PortBindings: map[string]string{"80/tcp": "8080"}, Cmd: []string{"true"}}, "PortBindings"},
+		{"mounts", Config{Mounts: []Mount{{Type: TmpfsMount, Target: "/tmp"}}, Cmd: []string{"true"}}, "Mounts"},
+	}
+	p := NewProcess(t.TempDir())
+	for _, tt := range tests {
+		err := p.Validate(tt.c)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProcessDoesNotInheritEnv(t *testing.T) {
+	t.Setenv("ORCHESTRATOR_TEST_SECRET", "secret")
+	p := NewProcess(t.TempDir())
+	result := p.Run(Config{Name: "env", Cmd: []string{"/usr/bin/env"}, Env: []string{"GREETING=hello"}})
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	waitExited(t, p, result.ContainerId)
+	out := processOutput(t, p, result.ContainerId)
+	if !strings.Contains(out, "GREETING=hello") {
+		t.Errorf("environment of the task is missing: %q", out)
+	}
+	if strings.Contains(out, "ORCHESTRATOR_TEST_SECRET") {
+		t.Errorf("environment of the worker is inherited: %q", out)
+	}
+}
+
+func TestProcessStopSignalsGroupAfterExit(t *testing.T) {
+	p := NewProcess(t.TempDir())
+	// The command exits right away and leaves a child behind
+	result := p.Run(Config{
+		Name:            "group",
+		Cmd:             []string{"/bin/sh", "-c", "sleep 60 & echo $!"},
+		StopGracePeriod: 5 * time.Second,
+	})
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	waitExited(t, p, result.ContainerId)
+	pid, err := strconv.Atoi(strings.TrimSpace(processOutput(t, p, result.ContainerId)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processGone(pid) {
+		t.Fatal("child of the command is not running")
+	}
+	result = p.Stop(result.ContainerId)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for !processGone(pid) {
+		if time.Now().After(deadline) {
+			t.Fatal("child of the command has not been stopped")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func waitExited(t *testing.T, p *Process, id string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Inspect(id).Status != "exited" {
+		if time.Now().After(deadline) {
+			t.Fatalf("process %s has not exited", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func processOutput(t *testing.T, p *Process, id string) string {
+	t.Helper()
+	var out bytes.Buffer
+	err := p.Logs(context.Background(), id, LogsOptions{Stdout: true}, &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+// processGone checks whether the process has exited. Zombies
+// count as exited, as nothing may be reaping them.
+func processGone(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] == "Z"
+}
@@ -0,0 +1,65 @@
+package task
+
+import "fmt"
+
+type RestartPolicy string
+
+const (
+	// RestartNever leaves failed tasks failed
+	RestartNever RestartPolicy = "never"
+	// RestartOnFailure restarts tasks that have failed
+	// or have become unhealthy
+	RestartOnFailure RestartPolicy = "on-failure"
+	// RestartAlways also restarts tasks that have exited
+	// successfully, unless they have been stopped
+	RestartAlways RestartPolicy = "always"
+)
+
+// defaultMaxRestarts is the restart limit
+// of tasks without MaxRestarts.
+const defaultMaxRestarts = 3
+
+// Policy returns the restart policy of the task. Docker's
+// "no" and "unless-stopped" are accepted as aliases
+// of never and always.
+func (t *Task) Policy() RestartPolicy {
+	switch t.RestartPolicy {
+	case "", RestartOnFailure:
+		return RestartOnFailure
+	case RestartNever, "no":
+		return RestartNever
+	case RestartAlways, "unless-stopped":
+		return RestartAlways
+	}
+	return t.RestartPolicy
+}
+
+// ValidateRestartPolicy checks that the
+// restart policy of the task is known.
+func (t *Task) ValidateRestartPolicy() error {
+	switch t.Policy() {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unknown restart policy %q", t.RestartPolicy)
+	}
+	if t.MaxRestarts < -1 {
+		return fmt.Errorf("invalid MaxRestarts %d", t.MaxRestarts)
+	}
+	return nil
+}
+
+// CanRestart checks whether the task
+// has restarts left.
+func (t *Task) CanRestart() bool {
+	if t.Policy() == RestartNever {
+		return false
+	}
+	switch {
+	case t.MaxRestarts < 0:
+		return true
+	case t.MaxRestarts == 0:
+		return t.RestartCount < defaultMaxRestarts
+	default:
+		return t.RestartCount < t.MaxRestarts
+	}
+}
@@ -0,0 +1,153 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/docker/go-connections/nat"
+)
+
+type RuntimeType string
+
+const (
+	DockerRuntime  RuntimeType = "docker"
+	ProcessRuntime RuntimeType = "process"
+)
+
+// Runtime is an interface that every driver
+// used by a worker to run tasks has to implement.
+// A driver is identified by the id it returns from Run,
+// which is stored by the worker in Task.ContainerID.
+type Runtime interface {
+	// Validate returns an error if Config c
+	// uses fields the runtime can not honor.
+	Validate(c Config) error
+	// Run starts a new instance of a task
+	// described by Config c.
+	Run(c Config) RuntimeResult
+	// Stop stops and removes the instance
+	// with the given id.
+	Stop(id string) RuntimeResult
+	// Inspect reports the current state
+	// of the instance with the given id.
+	Inspect(id string) InspectResponse
+	// Logs copies output of the instance with the given id
+	// to stdout and stderr writers according to opts until
+	// ctx is done.
+	Logs(ctx context.Context, id string, opts LogsOptions, stdout, stderr io.Writer) error
+}
+
+// RuntimeResult is a convenience struct.
+// It is used as a return value in methods
+// that start or stop tasks, providing a
+// wrapper around common information that is
+// useful for callers.
+//
+// Action field specifies the action being taken, e.g. start or stop.
+// ContainerId specifies the container (or any other runtime
+// instance) to which the result belongs.
+// Result field can hold arbitrary text that provides more information
+// about the result of the operation.
+type RuntimeResult struct {
+	Error       error
+	Action      string
+	ContainerId string
+	Result      string
+	// Events that occurred while the
+	// operation was performed
+	Events []Event
+}
+
+// InspectResponse is a runtime independent
+// description of a running task instance.
+type InspectResponse struct {
+	Error error
+	// Status is the state of the instance as
+	// reported by the runtime (running, exited, etc.)
+	Status   string
+	ExitCode int
+	// HostPorts stores ports of the host
+	// the instance's ports are published to
+	HostPorts nat.PortMap
+}
+
+// ErrUnsupportedLogsOption is returned by Runtime.Logs
+// for options the runtime is not able to honor
+var ErrUnsupportedLogsOption = errors.New("unsupported logs option")
+
+// LogsOptions controls which part of an
+// instance's output is returned by Runtime.Logs.
+type LogsOptions struct {
+	// Follow keeps the output open until
+	// the instance stops
+	Follow bool
+	// Tail is the number of lines to show from
+	// the end of the logs, or "all"
+	Tail string
+	// Since only returns logs produced after the given
+	// timestamp (RFC3339) or relative duration (e.g. 10m)
+	Since  string
+	Stdout bool
+	Stderr bool
+}
+
+// Executor is implemented by runtimes able to run
+// additional commands inside a running task.
+type Executor interface {
+	// Exec runs a command inside the instance with the given id
+	// and returns its exit code once it completes. Command reads
+	// stdin when it is not nil. With a TTY output of the command
+	// is written to stdout only. The command is no longer
+	// waited for once ctx is done.
+	Exec(ctx context.Context, id string, opts ExecOptions, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+}
+
+// ExecOptions describes a command run inside a task.
+type ExecOptions struct {
+	Cmd []string
+	// Tty allocates a pseudo-terminal for the command
+	Tty bool
+	// Interactive keeps stdin of the command open
+	Interactive bool
+}
+
+// ExecResult is returned for commands
+// run without an interactive session.
+type ExecResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// Labels every instance started by
+// a worker is marked with.
+const (
+	LabelTaskID   = "orchestrator.task.id"
+	LabelTaskName = "orchestrator.task.name"
+	LabelWorker   = "orchestrator.worker"
+	// LabelSidecar is the name of a sidecar
+	LabelSidecar = "orchestrator.sidecar.name"
+	// LabelSidecarOf is the id of the main
+	// instance of a sidecar's task group
+	LabelSidecarOf = "orchestrator.sidecar.of"
+	// LabelInitContainer is the name of an init container
+	LabelInitContainer = "orchestrator.init.name"
+)
+
+// Lister is implemented by runtimes able to list
+// instances they have started, including the ones
+// workers have lost track of.
+type Lister interface {
+	// List returns all instances marked with LabelTaskID
+	List() ([]Instance, error)
+}
+
+// Instance is a task instance known to a runtime.
+type Instance struct {
+	ID     string
+	Labels map[string]string
+	// Status is the state of the instance as
+	// reported by the runtime (running, exited, etc.)
+	Status string
+}
@@ -1,11 +1,13 @@
 package task
 
 var stateTransitionMap = map[State][]State{
-	Pending:   []State{Scheduled},
-	Scheduled: []State{Scheduled, Running, Failed},
-	Running:   []State{Running, Completed},
-	Completed: []State{},
-	Failed:    []State{},
+	Pending:   []State{Scheduled, Failed, Skipped},
+	Scheduled: []State{Scheduled, Running, Completed, Failed, Lost},
+	Running:   []State{Running, Scheduled, Completed, Failed, Lost},
+	Completed: []State{Scheduled},
+	Failed:    []State{Scheduled},
+	Skipped:   []State{},
+	Lost:      []State{Scheduled},
 }
 
 func Contains(states []State, state State) bool {
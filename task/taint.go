@@ -0,0 +1,101 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+)
+
+type TaintEffect string
+
+const (
+	// NoSchedule keeps tasks that do not tolerate
+	// the taint from being scheduled onto the node
+	NoSchedule TaintEffect = "NoSchedule"
+	// PreferNoSchedule makes the scheduler avoid the
+	// node for tasks that do not tolerate the taint
+	PreferNoSchedule TaintEffect = "PreferNoSchedule"
+	// NoExecute works like NoSchedule and also evicts
+	// running tasks that do not tolerate the taint
+	NoExecute TaintEffect = "NoExecute"
+)
+
+type TolerationOperator string
+
+const (
+	// Equal tolerates taints with the same key and value
+	Equal TolerationOperator = "Equal"
+	// Exists tolerates taints with the same key,
+	// or every taint when the key is empty
+	TolerationExists TolerationOperator = "Exists"
+)
+
+// Taint repels tasks that do not tolerate it from a node.
+type Taint struct {
+	Key    string
+	Value  string
+	Effect TaintEffect
+}
+
+func (t Taint) String() string {
+	if t.Value == "" {
+		return fmt.Sprintf("%s:%s", t.Key, t.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+}
+
+// Validate checks that the taint is well-formed.
+func (t Taint) Validate() error {
+	if t.Key == "" {
+		return errors.New("taint requires a key")
+	}
+	switch t.Effect {
+	case NoSchedule, PreferNoSchedule, NoExecute:
+	default:
+		return fmt.Errorf("unknown taint effect %q", t.Effect)
+	}
+	return nil
+}
+
+// Toleration allows a task onto nodes with matching taints.
+type Toleration struct {
+	Key string
+	// Operator is either Equal (default) or Exists
+	Operator TolerationOperator
+	Value    string
+	// Effect to tolerate, empty tolerates all effects
+	Effect TaintEffect
+}
+
+// Tolerates checks whether the toleration matches the taint.
+func (t Toleration) Tolerates(taint Taint) bool {
+	if t.Effect != "" && t.Effect != taint.Effect {
+		return false
+	}
+	if t.Operator == TolerationExists {
+		return t.Key == "" || t.Key == taint.Key
+	}
+	return t.Key == taint.Key && t.Value == taint.Value
+}
+
+// Tolerates checks whether any toleration
+// of the task matches the taint.
+func (t *Task) Tolerates(taint Taint) bool {
+	for _, toleration := range t.Tolerations {
+		if toleration.Tolerates(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// UntoleratedTaints returns taints with the given effect
+// that none of the tolerations of the task match.
+func (t *Task) UntoleratedTaints(taints []Taint, effect TaintEffect) []Taint {
+	var untolerated []Taint
+	for _, taint := range taints {
+		if taint.Effect == effect && !t.Tolerates(taint) {
+			untolerated = append(untolerated, taint)
+		}
+	}
+	return untolerated
+}
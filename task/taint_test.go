@@ -0,0 +1,82 @@
+package task
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTolerates(t *testing.T) {
+	taint := Taint{Key: "team", Value: "data", Effect: NoExecute}
+	tests := []struct {
+		name string
+		tol  Toleration
+		want bool
+	}{
+		{"equal", Toleration{Key: "team", Value: "data"}, true},
+		{"explicit equal", Toleration{Key: "team", Operator: Equal, Value: "data", Effect: NoExecute}, true},
+		{"other value", Toleration{Key: "team", Value: "web"}, false},
+		{"other key", Toleration{Key: "gpu", Value: "data"}, false},
+		{"exists", Toleration{Key: "team", Operator: TolerationExists}, true},
+		{"exists other key", Toleration{Key: "gpu", Operator: TolerationExists}, false},
+		{"exists everything", Toleration{Operator: TolerationExists}, true},
+		{"effect", Toleration{Key: "team", Value: "data", Effect: NoExecute}, true},
+		{"other effect", Toleration{Key: "team", Value: "data", Effect: NoSchedule}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.tol.Tolerates(taint); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUntoleratedTaints(t *testing.T) {
+	taints := []Taint{
+		{Key: "team", Value: "data", Effect: NoSchedule},
+		{Key: "gpu", Effect: NoSchedule},
+		{Key: "maintenance", Effect: NoExecute},
+		{Key: "spot", Effect: PreferNoSchedule},
+	}
+	tests := []struct {
+		name        string
+		tolerations []Toleration
+		effect      TaintEffect
+		want        []string
+	}{
+		{"none tolerated", nil, NoSchedule, []string{"team=data:NoSchedule", "gpu:NoSchedule"}},
+		{"one tolerated", []Toleration{{Key: "team", Value: "data"}}, NoSchedule, []string{"gpu:NoSchedule"}},
+		{"other effect tolerated", []Toleration{{Key: "gpu", Operator: TolerationExists, Effect: NoExecute}}, NoSchedule, []string{"team=data:NoSchedule", "gpu:NoSchedule"}},
+		{"no execute", []Toleration{{Key: "team", Value: "data"}}, NoExecute, []string{"maintenance:NoExecute"}},
+		{"everything tolerated", []Toleration{{Operator: TolerationExists}}, NoExecute, nil},
+		{"prefer no schedule", nil, PreferNoSchedule, []string{"spot:PreferNoSchedule"}},
+	}
+	for _, tt := range tests {
+		tk := Task{Tolerations: tt.tolerations}
+		var got []string
+		for _, taint := range tk.UntoleratedTaints(taints, tt.effect) {
+			got = append(got, taint.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTaintValidate(t *testing.T) {
+	tests := []struct {
+		taint   Taint
+		wantErr bool
+	}{
+		{Taint{Key: "team", Value: "data", Effect: NoSchedule}, false},
+		{Taint{Key: "gpu", Effect: PreferNoSchedule}, false},
+		{Taint{Key: "maintenance", Effect: NoExecute}, false},
+		{Taint{Effect: NoSchedule}, true},
+		{Taint{Key: "team", Effect: "NoRun"}, true},
+		{Taint{Key: "team"}, true},
+	}
+	for _, tt := range tests {
+		err := tt.taint.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.taint, err, tt.wantErr)
+		}
+	}
+}
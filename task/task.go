@@ -1,7 +1,7 @@
 package task
 
 import (
-	"github.com/docker/docker/api/types"
+	"strings"
 	"time"
 
 	"github.com/docker/go-connections/nat"
@@ -16,56 +16,313 @@ const (
 	Running
 	Completed
 	Failed
+	// Skipped tasks of a workflow are never run
+	// because one of their dependencies has failed
+	Skipped
+	// Lost tasks were assigned to a worker that has
+	// stopped responding. They are rescheduled.
+	Lost
 )
 
 func (s State) String() []string {
-	return []string{"Pending", "Scheduled", "Running", "Completed", "Failed"}
+	return []string{"Pending", "Scheduled", "Running", "Completed", "Failed", "Skipped", "Lost"}
 }
 
+// DefaultStopGracePeriod is the time tasks are
+// given to exit before they are killed
+const DefaultStopGracePeriod = 10 * time.Second
+
+type Kind string
+
+const (
+	// Service is a long-running task
+	Service Kind = "service"
+	// Job is a task that runs to completion
+	Job Kind = "job"
+)
+
 type Task struct {
 	// Unique identifier
 	ID          uuid.UUID
 	ContainerID string
+	// SidecarIDs are ids of instances of sidecars
+	// of the task, in the order they have been started
+	SidecarIDs []string
 	// Human-readable name
 	Name  string
 	State State
-	// Orchestrator will only work with Docker containers
-	// Image is the name of a Docker container image
+	// Worker is the <hostname>:<port> address of the
+	// worker the task has been scheduled onto
+	Worker string
+	// Labels identify the task, e.g. app=web, to
+	// anti-affinities and spread constraints
+	Labels map[string]string
+	// Kind is either service (default) or job
+	Kind Kind
+	// ExitCode is the exit code of the task's
+	// instance once it has exited
+	ExitCode int
+	// BackoffLimit is the number of times a failed
+	// job is retried before it is considered failed
+	BackoffLimit int
+	// Completions is the number of job instances that
+	// have to complete successfully. Parallelism limits
+	// the number of instances running at the same time
+	// and defaults to Completions. A job with either of
+	// them greater than 1 is run as a set of instances.
+	Completions int
+	Parallelism int
+	// JobID is the ID of the job
+	// the task is an instance of
+	JobID uuid.UUID
+	// Image is the name of a Docker container image.
+	// It is not used by the process runtime.
 	Image string
+	// ImagePullPolicy is one of Always, IfNotPresent
+	// or Never. Images tagged latest are always pulled
+	// by default, other images only if missing.
+	ImagePullPolicy PullPolicy
+	// RegistryAuth is a name of registry credentials
+	// configured on the worker used to pull the image.
+	// Credentials of the image's registry are used
+	// when it is empty.
+	RegistryAuth string
+	// Entrypoint overrides the entrypoint of the image
+	Entrypoint []string
+	// Cmd overrides the command of the image.
+	// The process runtime requires either
+	// Entrypoint or Cmd to be set.
+	Cmd []string
+	// Args are appended to Cmd
+	Args []string
+	// Env is used to specify ENV variables
+	Env []string
+	// WorkingDir is the directory
+	// the command is started in
+	WorkingDir string
+	// User (name or uid[:gid]) the command is run as
+	User string
 	// Allocated memory
 	Memory int64
-	// Allocated disk space
+	// Disk space in bytes the task needs. It is only
+	// used for scheduling and is not enforced.
 	Disk int64
 	// These fields will be used by Docker
 	// to ensure the machine allocates the proper
 	// network ports for the task, and it is
 	// available on the network
-	Cpu           float64
-	ExposedPorts  nat.PortSet
-	HostPorts     nat.PortMap
-	PortBindings  map[string]string
-	RestartPolicy string
-	StartTime     time.Time
-	FinishTime    time.Time
+	Cpu          float64
+	ExposedPorts nat.PortSet
+	HostPorts    nat.PortMap
+	// PortBindings maps container ports (e.g. 7777/tcp)
+	// to host ports. Exposed ports without an explicit
+	// binding get a host port allocated by the manager.
+	PortBindings map[string]string
+	// AllocatedPorts maps exposed ports without an explicit
+	// binding to host ports allocated by the manager on the
+	// worker the task is placed on
+	AllocatedPorts map[string]string
+	// NamedPorts gives names to container ports,
+	// e.g. "http": "7777/tcp", so health checks
+	// and clients can refer to them
+	NamedPorts map[string]string
+	// NodeSelector lists labels every node the
+	// task is scheduled onto has to have
+	NodeSelector map[string]string
+	// NodeAffinity holds further requirements
+	// and preferences for nodes of the task
+	NodeAffinity *NodeAffinity
+	// AntiAffinity keeps the task off nodes
+	// running tasks with certain labels
+	AntiAffinity []TaskAntiAffinity
+	// TopologySpread spreads tasks evenly
+	// over zones, racks or nodes
+	TopologySpread []TopologySpreadConstraint
+	// Tolerations allow the task onto
+	// nodes with matching taints
+	Tolerations []Toleration
+	// RestartPolicy is one of never, on-failure (default)
+	// and always. It is applied by the manager to services,
+	// jobs are retried according to BackoffLimit.
+	RestartPolicy RestartPolicy
+	// MaxRestarts limits the number of restarts, -1 means
+	// no limit. Defaults to 3.
+	MaxRestarts int
+	// Mounts lists volumes, bind mounts
+	// and tmpfs mounts of the task
+	Mounts []Mount
+	// Sidecars are additional containers run together
+	// with the task on the same worker. Ports of sidecars
+	// are published by the task's container, as they
+	// share its network namespace.
+	Sidecars []Container
+	// InitContainers are run to completion one by
+	// one before the task is started, e.g. to run
+	// schema migrations
+	InitContainers []Container
+	// StopGracePeriodSeconds is the time the task is given
+	// to exit after it is asked to stop before it is
+	// killed. Defaults to 10.
+	StopGracePeriodSeconds int
+	// PostStart is run right after the task is started
+	// and PreStop right before it is stopped
+	PostStart  *Hook
+	PreStop    *Hook
+	StartTime  time.Time
+	FinishTime time.Time
 	// Endpoint for task health checks (used by manager)
-	HealthCheck  string
+	HealthCheck string
+	// HealthCheckPort is a name or a container port
+	// health checks are sent to. The first published
+	// port is used when it is empty.
+	HealthCheckPort string
+	// HealthProbe checks whether the running task is
+	// healthy. Unhealthy tasks are restarted. It takes
+	// precedence over HealthCheck.
+	HealthProbe *Probe
+	// Health is the outcome of health checks of the task
+	Health Health
+	// ReadinessProbe checks whether the running task is
+	// ready to receive traffic. Failures do not restart
+	// the task. Running tasks without it are always ready.
+	ReadinessProbe *Probe
+	// Readiness is the outcome of readiness checks
+	Readiness Health
+	// Ready tasks are running and pass their readiness
+	// probe. Only ready tasks are given traffic.
+	Ready        bool
 	RestartCount int
+	// RestartBackoff is the delay before the next restart.
+	// It grows exponentially with every restart.
+	RestartBackoff time.Duration
+	// NextRestartTime is when the task is going to be
+	// restarted, zero if no restart is pending
+	NextRestartTime time.Time
+	// Stopped is set when the task has been stopped on
+	// request, so it is not restarted by the always policy
+	Stopped bool
+	// Settled is set when the task has been left in its
+	// final state by the manager and is not restarted
+	Settled bool
+	// Reason explains the current state
+	// of the task, e.g. why it has failed
+	Reason string
+	// Events lists the most recent events
+	// that happened to the task
+	Events []Event
 }
 
-func NewConfig(t *Task) *Config {
-	return &Config{
-		Name:          t.Name,
-		Image:         t.Image,
-		RestartPolicy: t.RestartPolicy,
+// IsJobParent checks whether the task is a job
+// that is run as a set of instance tasks.
+func (t *Task) IsJobParent() bool {
+	return t.Kind == Job && t.JobID == uuid.Nil && (t.Completions > 1 || t.Parallelism > 1)
+}
+
+// HostPort returns the host port the given port
+// is published to. Port is either a name from
+// NamedPorts or a container port, e.g. 7777/tcp.
+func (t *Task) HostPort(port string) (string, bool) {
+	if p, ok := t.NamedPorts[port]; ok {
+		port = p
+	}
+	containerPort := nat.Port(port)
+	if !strings.Contains(port, "/") {
+		containerPort = nat.Port(port + "/tcp")
+	}
+	if bindings := t.HostPorts[containerPort]; len(bindings) > 0 {
+		return bindings[0].HostPort, true
+	}
+	if hostPort, ok := t.Bindings()[string(containerPort)]; ok {
+		return hostPort, true
+	}
+	return "", false
+}
+
+// Bindings returns explicit port bindings of
+// the task together with allocated ones.
+func (t *Task) Bindings() map[string]string {
+	if len(t.AllocatedPorts) == 0 {
+		return t.PortBindings
+	}
+	bindings := make(map[string]string)
+	for port, hostPort := range t.AllocatedPorts {
+		bindings[port] = hostPort
 	}
+	for port, hostPort := range t.PortBindings {
+		bindings[port] = hostPort
+	}
+	return bindings
+}
+
+// StopGracePeriod returns the time the task
+// is given to exit when it is stopped.
+func (t *Task) StopGracePeriod() time.Duration {
+	if t.StopGracePeriodSeconds <= 0 {
+		return DefaultStopGracePeriod
+	}
+	return time.Duration(t.StopGracePeriodSeconds) * time.Second
+}
+
+// Resources returns CPU, memory and disk
+// requested by the task and its sidecars.
+func (t *Task) Resources() (float64, int64, int64) {
+	cpu, memory, disk := t.Cpu, t.Memory, t.Disk
+	// Init containers run before the task, so
+	// they never need more than the task itself
+	for _, s := range t.Sidecars {
+		cpu += s.Cpu
+		memory += s.Memory
+		disk += s.Disk
+	}
+	return cpu, memory, disk
+}
+
+// Ports returns ports exposed by the task and its sidecars.
+func (t *Task) Ports() nat.PortSet {
+	ports := make(nat.PortSet)
+	for port := range t.ExposedPorts {
+		ports[port] = struct{}{}
+	}
+	for _, s := range t.Sidecars {
+		for port := range s.ExposedPorts {
+			ports[port] = struct{}{}
+		}
+	}
+	return ports
 }
 
-// DockerInspectResponse is a wrapper struct to
-// work with responses from ContainerInspect.
-type DockerInspectResponse struct {
-	Error error
-	// Container stores data about a
-	// docker container, including
-	// its state (running, failed, etc.)
-	Container *types.ContainerJSON
+func NewConfig(t *Task) *Config {
+	var sidecars []Config
+	for _, s := range t.Sidecars {
+		sidecars = append(sidecars, sidecarConfig(t, s))
+	}
+	var initContainers []Config
+	for _, s := range t.InitContainers {
+		initContainers = append(initContainers, initContainerConfig(t, s))
+	}
+	return &Config{
+		Labels: map[string]string{
+			LabelTaskID:   t.ID.String(),
+			LabelTaskName: t.Name,
+		},
+		Mounts:          t.Mounts,
+		Name:            t.Name,
+		Image:           t.Image,
+		ImagePullPolicy: t.ImagePullPolicy,
+		RegistryAuth:    t.RegistryAuth,
+		Entrypoint:      t.Entrypoint,
+		Cmd:             t.Cmd,
+		Args:            t.Args,
+		Env:             t.Env,
+		WorkingDir:      t.WorkingDir,
+		User:            t.User,
+		Cpu:             t.Cpu,
+		Memory:          t.Memory,
+		Disk:            t.Disk,
+		ExposedPorts:    t.Ports(),
+		PortBindings:    t.Bindings(),
+		Sidecars:        sidecars,
+		InitContainers:  initContainers,
+		StopGracePeriod: t.StopGracePeriod(),
+	}
 }
@@ -0,0 +1,113 @@
+package task
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FailurePolicy string
+
+const (
+	// SkipDownstream marks tasks depending on
+	// a failed task as skipped
+	SkipDownstream FailurePolicy = "skip"
+	// FailDownstream marks tasks depending on
+	// a failed task as failed
+	FailDownstream FailurePolicy = "fail"
+)
+
+// Workflow is a set of tasks with dependencies
+// between them. A task is only run once all
+// of its dependencies have completed.
+type Workflow struct {
+	ID   uuid.UUID
+	Name string
+	// Tasks are the members of the workflow
+	Tasks []WorkflowTask
+	// FailurePolicy defines what happens to tasks
+	// depending on a failed task. Defaults to skip.
+	FailurePolicy FailurePolicy
+	// State aggregates states of the members. The workflow
+	// is Running while any of them is, Completed when all
+	// of them have completed and Failed otherwise.
+	State        State
+	CreationTime time.Time
+	FinishTime   time.Time
+}
+
+// WorkflowTask is a member of a workflow.
+type WorkflowTask struct {
+	// Name identifies the member within the workflow
+	Name string
+	// DependsOn lists names of members that
+	// have to complete before the task is run
+	DependsOn []string
+	// Task is a template of the submitted task.
+	// It is run as a job whatever its Kind is.
+	Task Task
+	// TaskID is the ID of the submitted task
+	TaskID uuid.UUID
+	State  State
+	Reason string
+}
+
+// Validate checks that names of members are unique,
+// all dependencies exist and there are no cycles.
+func (w *Workflow) Validate() error {
+	if len(w.Tasks) == 0 {
+		return fmt.Errorf("workflow %s has no tasks", w.Name)
+	}
+	members := make(map[string]*WorkflowTask)
+	for i := range w.Tasks {
+		wt := &w.Tasks[i]
+		if wt.Name == "" {
+			return fmt.Errorf("task %d of workflow %s has no name", i, w.Name)
+		}
+		if _, ok := members[wt.Name]; ok {
+			return fmt.Errorf("duplicate task %s in workflow %s", wt.Name, w.Name)
+		}
+		members[wt.Name] = wt
+	}
+	for _, wt := range w.Tasks {
+		for _, dep := range wt.DependsOn {
+			if _, ok := members[dep]; !ok {
+				return fmt.Errorf("task %s depends on unknown task %s", wt.Name, dep)
+			}
+		}
+	}
+	// Depth-first search, a member being
+	// visited twice on a path is a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle through task %s in workflow %s", name, w.Name)
+		case visited:
+			return nil
+		}
+		marks[name] = visiting
+		for _, dep := range members[name].DependsOn {
+			err := visit(dep)
+			if err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		return nil
+	}
+	for _, wt := range w.Tasks {
+		err := visit(wt.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
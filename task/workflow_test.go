@@ -0,0 +1,32 @@
+package task
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWorkflowValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tasks   []WorkflowTask
+		wantErr string
+	}{
+		{"valid", []WorkflowTask{{Name: "a"}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c", DependsOn: []string{"a", "b"}}}, ""},
+		{"no tasks", nil, "has no tasks"},
+		{"unnamed", []WorkflowTask{{Name: "a"}, {}}, "task 1 of workflow w has no name"},
+		{"duplicate", []WorkflowTask{{Name: "a"}, {Name: "a"}}, "duplicate task a"},
+		{"unknown dependency", []WorkflowTask{{Name: "a", DependsOn: []string{"b"}}}, "depends on unknown task b"},
+		{"self dependency", []WorkflowTask{{Name: "a", DependsOn: []string{"a"}}}, "dependency cycle"},
+		{"cycle", []WorkflowTask{{Name: "a", DependsOn: []string{"c"}}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c", DependsOn: []string{"b"}}}, "dependency cycle"},
+	}
+	for _, tt := range tests {
+		w := &Workflow{Name: "w", Tasks: tt.tasks}
+		err := w.Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+)
+
+// FlushWriter flushes every write to the underlying
+// http.ResponseWriter, so clients get streamed output
+// (e.g. logs) as soon as it is produced.
+type FlushWriter struct {
+	W io.Writer
+}
+
+func (f FlushWriter) Write(p []byte) (int, error) {
+	n, err := f.W.Write(p)
+	if flusher, ok := f.W.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
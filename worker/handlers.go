@@ -1,13 +1,22 @@
 package worker
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/vasilii314/orchestrator/task"
+	"github.com/vasilii314/orchestrator/utils"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 type ErrResponse struct {
@@ -32,6 +41,18 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(e)
 		return
 	}
+	err = a.Worker.Validate(&taskEvent.Task)
+	if err != nil {
+		msg := fmt.Sprintf("task %v rejected: %v", taskEvent.Task.ID, err)
+		log.Printf("[worker.Api] [StartTaskHandler] %s\n", msg)
+		w.WriteHeader(http.StatusBadRequest)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
 	a.Worker.AddTask(taskEvent.Task)
 	log.Printf("[worker.Api] [StartTaskHandler] task added: %v\n", taskEvent.Task.ID)
 	w.WriteHeader(http.StatusCreated)
@@ -70,3 +91,216 @@ func (a *Api) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(a.Worker.Stats)
 }
+
+// GetTaskLogsHandler streams output of a task. Query parameters
+// follow, tail, since, stdout and stderr control what is returned.
+// Both stdout and stderr are returned when neither is set.
+func (a *Api) GetTaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	tID, _ := uuid.Parse(taskID)
+	t, err := a.Worker.Db.Get(tID.String())
+	if err != nil {
+		log.Printf("[worker.Api] [GetTaskLogsHandler] No task with ID %v found\n", tID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if t.ContainerID == "" {
+		log.Printf("[worker.Api] [GetTaskLogsHandler] Task %v has not been started\n", tID)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	query := r.URL.Query()
+	opts := task.LogsOptions{
+		Follow: query.Get("follow") == "true",
+		Tail:   query.Get("tail"),
+		Since:  query.Get("since"),
+		Stdout: query.Get("stdout") == "true",
+		Stderr: query.Get("stderr") == "true",
+	}
+	if !opts.Stdout && !opts.Stderr {
+		opts.Stdout = true
+		opts.Stderr = true
+	}
+	out := &logsWriter{w: w}
+	err = a.Worker.Runtime.Logs(r.Context(), t.ContainerID, opts, out, out)
+	if err != nil {
+		log.Printf("[worker.Api] [GetTaskLogsHandler] Error streaming logs of task %v: %v\n", tID, err)
+	}
+	if out.started {
+		// Errors after the logs have started
+		// can only cut the stream short
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, task.ErrUnsupportedLogsOption) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: status, Message: err.Error()})
+		return
+	}
+	out.start()
+}
+
+// logsWriter writes the status of a logs response with the
+// first chunk of logs, so errors the runtime returns before
+// any logs have been written are reported with their status.
+type logsWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (l *logsWriter) start() {
+	if !l.started {
+		l.started = true
+		l.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		l.w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (l *logsWriter) Write(p []byte) (int, error) {
+	l.start()
+	return utils.FlushWriter{W: l.w}.Write(p)
+}
+
+// ExecTaskHandler runs a command inside a running task. Requests
+// with "Connection: Upgrade" and "Upgrade: tcp" headers are switched
+// to a raw stream used as stdin of the command. Its output is sent
+// back multiplexed with stdcopy, followed by its exit code on the
+// stdcopy.Systemerr stream. The command is cancelled once the
+// stream fails. Other requests run the command to completion
+// and get task.ExecResult back.
+func (a *Api) ExecTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	tID, _ := uuid.Parse(taskID)
+	t, err := a.Worker.Db.Get(tID.String())
+	if err != nil {
+		log.Printf("[worker.Api] [ExecTaskHandler] No task with ID %v found\n", tID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if t.State != task.Running {
+		msg := fmt.Sprintf("task %v is not running", tID)
+		log.Printf("[worker.Api] [ExecTaskHandler] %s\n", msg)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusConflict, Message: msg})
+		return
+	}
+	executor, ok := a.Worker.Runtime.(task.Executor)
+	if !ok {
+		msg := "runtime of the worker does not support exec"
+		log.Printf("[worker.Api] [ExecTaskHandler] %s\n", msg)
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusNotImplemented, Message: msg})
+		return
+	}
+	opts := task.ExecOptions{}
+	err = json.NewDecoder(r.Body).Decode(&opts)
+	if err != nil || len(opts.Cmd) == 0 {
+		msg := fmt.Sprintf("invalid exec request: %v", err)
+		log.Printf("[worker.Api] [ExecTaskHandler] %s\n", msg)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: msg})
+		return
+	}
+
+	if isUpgrade(r) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			log.Println("[worker.Api] [ExecTaskHandler] Connection can not be hijacked")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			log.Printf("[worker.Api] [ExecTaskHandler] Error hijacking connection: %v\n", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buf.Flush()
+		// The request's context is not cancelled once the connection
+		// has been hijacked, so failures of the connection cancel
+		// the command instead
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		var stdin io.Reader
+		if opts.Interactive {
+			stdin = connReader{r: buf.Reader, cancel: cancel}
+		} else {
+			go io.Copy(io.Discard, connReader{r: buf.Reader, cancel: cancel})
+		}
+		out := connWriter{w: conn, cancel: cancel}
+		stdout := stdcopy.NewStdWriter(out, stdcopy.Stdout)
+		stderr := stdcopy.NewStdWriter(out, stdcopy.Stderr)
+		exitCode, err := executor.Exec(ctx, t.ContainerID, opts, stdin, stdout, stderr)
+		if err != nil {
+			log.Printf("[worker.Api] [ExecTaskHandler] Error running %v in task %v: %v\n", opts.Cmd, tID, err)
+			fmt.Fprintf(stderr, "error running %v in task %v: %v\r\n", opts.Cmd, tID, err)
+			exitCode = execErrorExitCode
+		}
+		log.Printf("[worker.Api] [ExecTaskHandler] Command %v in task %v exited with code %d\n", opts.Cmd, tID, exitCode)
+		stdcopy.NewStdWriter(conn, stdcopy.Systemerr).Write([]byte(strconv.Itoa(exitCode)))
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode, err := executor.Exec(r.Context(), t.ContainerID, opts, nil, &stdout, &stderr)
+	if err != nil {
+		msg := fmt.Sprintf("error running %v in task %v: %v", opts.Cmd, tID, err)
+		log.Printf("[worker.Api] [ExecTaskHandler] %s\n", msg)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: msg})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task.ExecResult{
+		ExitCode: exitCode,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	})
+}
+
+// connReader reads a hijacked connection and cancels the
+// command once reading fails. The end of stdin, when the
+// client closes its side of the connection, does not.
+type connReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (c connReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && err != io.EOF {
+		c.cancel()
+	}
+	return n, err
+}
+
+// connWriter writes to a hijacked connection and cancels the
+// command once writing fails, e.g. the client has gone away.
+type connWriter struct {
+	w      io.Writer
+	cancel context.CancelFunc
+}
+
+func (c connWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if err != nil {
+		c.cancel()
+	}
+	return n, err
+}
+
+// execErrorExitCode is reported to interactive sessions
+// of commands that could not be run, same as by Docker
+const execErrorExitCode = 126
+
+// isUpgrade checks whether the client asks
+// to switch the connection to a raw stream.
+func isUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "tcp") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// Heartbeat is sent by the worker to the manager
+// to report that it is alive, along with its stats.
+type Heartbeat struct {
+	// Name is the <hostname>:<port> address
+	// the manager reaches the worker at
+	Name   string
+	Cores  int
+	Labels map[string]string
+	Stats  Stats
+}
+
+// SendHeartbeats periodically sends heartbeats to the
+// manager. Address is the address of the worker's API.
+// A worker with a join token registers again if the
+// manager has forgotten about it.
+func (w *Worker) SendHeartbeats(manager, address string) {
+	for {
+		err := w.sendHeartbeat(manager, address)
+		if errors.Is(err, errUnknownWorker) && w.JoinToken != "" {
+			log.Printf("[worker.Worker] [SendHeartbeats] Registering with manager %s again\n", manager)
+			err = w.Join(manager, address)
+		}
+		if err != nil {
+			log.Printf("[worker.Worker] [SendHeartbeats] Error sending heartbeat to %s: %v\n", manager, err)
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (w *Worker) sendHeartbeat(manager, address string) error {
+	stats := w.Stats
+	if stats == nil {
+		stats = GetStats()
+	}
+	hb := Heartbeat{
+		Name:   address,
+		Cores:  runtime.NumCPU(),
+		Labels: w.Labels,
+		Stats:  *stats,
+	}
+	data, err := json.Marshal(hb)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("http://%s/nodes/heartbeat", manager)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.JoinToken))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errUnknownWorker
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("manager returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// runInitContainers runs init containers of the task
+// one by one. Every one of them has to exit with code 0
+// before the next one is started.
+func (w *Worker) runInitContainers(t *task.Task, c *task.Config) error {
+	for i, ic := range c.InitContainers {
+		name := ic.Labels[task.LabelInitContainer]
+		log.Printf("[worker.Worker] [runInitContainers] Running init container %s of task %v\n", name, t.ID)
+		result := w.Runtime.Run(ic)
+		t.AddEvents(result.Events...)
+		if result.Error != nil {
+			err := fmt.Errorf("init container %s: %v", name, result.Error)
+			t.AddEvents(task.NewEvent("InitContainerFailed", err.Error()))
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.InitContainers[i].Timeout())
+		exitCode, err := w.waitForExit(ctx, result.ContainerId)
+		cancel()
+		w.Runtime.Stop(result.ContainerId)
+		if err == nil && exitCode != 0 {
+			err = fmt.Errorf("exited with code %d", exitCode)
+		}
+		if err != nil {
+			err = fmt.Errorf("init container %s: %v", name, err)
+			t.AddEvents(task.NewEvent("InitContainerFailed", err.Error()))
+			return err
+		}
+		t.AddEvents(task.NewEvent("InitContainerCompleted", fmt.Sprintf("Init container %s has completed", name)))
+	}
+	return nil
+}
+
+// waitForExit polls the instance until it exits and
+// returns its exit code, or fails once ctx is done.
+func (w *Worker) waitForExit(ctx context.Context, id string) (int, error) {
+	for {
+		resp := w.Runtime.Inspect(id)
+		if resp.Error != nil {
+			return -1, resp.Error
+		}
+		if resp.Status == "exited" || resp.Status == "dead" {
+			return resp.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return -1, errors.New("did not complete in time")
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// runHook runs a lifecycle hook of a running task and
+// records its failure in the task's events.
+func (w *Worker) runHook(t *task.Task, name string, h *task.Hook) error {
+	log.Printf("[worker.Worker] [runHook] Running %s hook of task %v\n", name, t.ID)
+	// The hook is cancelled once it times out
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout())
+	defer cancel()
+	var err error
+	if len(h.Exec) > 0 {
+		err = w.execHook(ctx, t, h)
+	} else {
+		err = w.httpHook(ctx, t, h)
+	}
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %v", h.Timeout())
+	}
+	if err != nil {
+		err = fmt.Errorf("%s hook failed: %v", name, err)
+		log.Printf("[worker.Worker] [runHook] Task %v: %v\n", t.ID, err)
+		t.AddEvents(task.NewEvent("Failed"+name+"Hook", err.Error()))
+	}
+	return err
+}
+
+func (w *Worker) execHook(ctx context.Context, t *task.Task, h *task.Hook) error {
+	executor, ok := w.Runtime.(task.Executor)
+	if !ok {
+		return errors.New("runtime does not support exec")
+	}
+	var out bytes.Buffer
+	exitCode, err := executor.Exec(ctx, t.ContainerID, task.ExecOptions{Cmd: h.Exec}, nil, &out, &out)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command exited with code %d: %s", exitCode, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+func (w *Worker) httpHook(ctx context.Context, t *task.Task, h *task.Hook) error {
+	port := h.HTTPGet.Port
+	if hostPort, ok := t.HostPort(port); ok {
+		port = hostPort
+	}
+	url := fmt.Sprintf("http://localhost:%s%s", port, h.HTTPGet.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// stopInstance runs the PreStop hook of the task if its
+// instance is running and stops the instance together
+// with its sidecars.
+func (w *Worker) stopInstance(t *task.Task) (task.RuntimeResult, error) {
+	var hookErr error
+	if t.PreStop != nil && w.Runtime.Inspect(t.ContainerID).Status == "running" {
+		hookErr = w.runHook(t, "PreStop", t.PreStop)
+	}
+	return w.stopContainers(t), hookErr
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// Registration is sent by the worker to
+// join the cluster managed by a manager.
+type Registration struct {
+	// Name is the name of the worker
+	Name string
+	// Address is the <hostname>:<port> address
+	// the manager reaches the worker's API at
+	Address string
+	Cores   int
+	// Memory is total memory in kilobytes
+	// and Disk is total disk space in bytes
+	Memory int64
+	Disk   int64
+	Labels map[string]string
+}
+
+// errUnknownWorker is returned when the manager
+// receives a heartbeat of a worker it does not know
+var errUnknownWorker = errors.New("worker is not registered with the manager")
+
+// Join registers the worker with the manager using
+// JoinToken. Address is the address of the worker's API.
+func (w *Worker) Join(manager, address string) error {
+	stats := w.Stats
+	if stats == nil {
+		stats = GetStats()
+	}
+	reg := Registration{
+		Name:    w.Name,
+		Address: address,
+		Cores:   runtime.NumCPU(),
+		Memory:  int64(stats.MemTotalKb()),
+		Disk:    int64(stats.DiskTotal()),
+		Labels:  w.Labels,
+	}
+	data, err := json.Marshal(reg)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("http://%s/nodes", manager)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.JoinToken))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		e := ErrResponse{}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("manager returned %s: %s", resp.Status, e.Message)
+	}
+	return nil
+}
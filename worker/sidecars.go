@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/vasilii314/orchestrator/task"
+)
+
+// startSidecars starts sidecars of the task one by one after
+// its container, joining the container's network namespace.
+func (w *Worker) startSidecars(t *task.Task, c *task.Config) error {
+	t.SidecarIDs = nil
+	for _, s := range c.Sidecars {
+		name := s.Labels[task.LabelSidecar]
+		s.Labels[task.LabelSidecarOf] = t.ContainerID
+		s.NetworkOf = t.ContainerID
+		result := w.Runtime.Run(s)
+		t.AddEvents(result.Events...)
+		if result.Error != nil {
+			err := fmt.Errorf("sidecar %s: %v", name, result.Error)
+			t.AddEvents(task.NewEvent("SidecarFailed", err.Error()))
+			return err
+		}
+		t.SidecarIDs = append(t.SidecarIDs, result.ContainerId)
+	}
+	return nil
+}
+
+// stopSidecars stops sidecars of the task
+// in the reverse order they have been started.
+func (w *Worker) stopSidecars(t *task.Task) {
+	for i := len(t.SidecarIDs) - 1; i >= 0; i-- {
+		result := w.Runtime.Stop(t.SidecarIDs[i])
+		if result.Error != nil {
+			log.Printf("[worker.Worker] [stopSidecars] Error stopping sidecar %s of task %v: %v\n", t.SidecarIDs[i], t.ID, result.Error)
+		}
+	}
+	t.SidecarIDs = nil
+}
+
+// stopContainers stops sidecars of the
+// task and then its own container.
+func (w *Worker) stopContainers(t *task.Task) task.RuntimeResult {
+	w.stopSidecars(t)
+	return w.Runtime.Stop(t.ContainerID)
+}
+
+// failedSidecar returns an error describing the first sidecar of
+// a running task that is not running anymore. A task group is only
+// healthy while all of its containers are running.
+func (w *Worker) failedSidecar(t *task.Task) error {
+	for i, id := range t.SidecarIDs {
+		name := id
+		if i < len(t.Sidecars) {
+			name = t.Sidecars[i].Name
+		}
+		resp := w.Runtime.Inspect(id)
+		if resp.Error != nil {
+			return fmt.Errorf("sidecar %s: %v", name, resp.Error)
+		}
+		if resp.Status != "running" {
+			return fmt.Errorf("sidecar %s is %s with exit code %d", name, resp.Status, resp.ExitCode)
+		}
+	}
+	return nil
+}
@@ -5,12 +5,25 @@ import (
 	"fmt"
 	"github.com/vasilii314/orchestrator/store"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-collections/collections/queue"
+	"github.com/google/uuid"
 	"github.com/vasilii314/orchestrator/task"
 )
 
+type OrphanPolicy string
+
+const (
+	// OrphanReport only logs orphaned instances
+	OrphanReport OrphanPolicy = "report"
+	// OrphanRemove stops and removes orphaned instances
+	OrphanRemove OrphanPolicy = "remove"
+)
+
 type Worker struct {
 	Name string
 	// Queue is needed to accept tasks from manager
@@ -22,12 +35,49 @@ type Worker struct {
 	// Convenience field
 	TaskCount int
 	Stats     *Stats
+	// Runtime is a driver used to run
+	// tasks on the machine, e.g. Docker
+	Runtime task.Runtime
+	// OrphanPolicy defines what happens to instances
+	// labelled with this worker's name that do not
+	// belong to any task the worker knows about
+	OrphanPolicy OrphanPolicy
+	// AllowedBindPaths lists host directories tasks
+	// are allowed to bind mount (including their
+	// subdirectories). Bind mounts are rejected
+	// when it is empty.
+	AllowedBindPaths []string
+	// Labels are reported to the manager, which
+	// matches them against node selectors of tasks
+	Labels map[string]string
+	// JoinToken authenticates the worker when
+	// it registers with the manager
+	JoinToken string
+	// mu guards Db and busy. RunTask starts and stops
+	// instances without holding it, so tasks in busy are
+	// left alone by updateTasks and reconcile meanwhile.
+	mu   sync.Mutex
+	busy map[uuid.UUID]bool
 }
 
-func New(name string, storeType store.StoreType) *Worker {
+func New(name string, storeType store.StoreType, runtimeType task.RuntimeType) (*Worker, error) {
 	w := Worker{
-		Name:  name,
-		Queue: *queue.New(),
+		Name:         name,
+		Queue:        *queue.New(),
+		OrphanPolicy: OrphanReport,
+		busy:         make(map[uuid.UUID]bool),
+	}
+	switch runtimeType {
+	case task.DockerRuntime:
+		d, err := task.NewDocker()
+		if err != nil {
+			return nil, fmt.Errorf("error creating Docker client: %v", err)
+		}
+		w.Runtime = d
+	case task.ProcessRuntime:
+		w.Runtime = task.NewProcess(fmt.Sprintf("%s_logs", name))
+	default:
+		return nil, fmt.Errorf("unknown runtime %q", runtimeType)
 	}
 	var s store.Store[string, *task.Task]
 	switch storeType {
@@ -40,7 +90,7 @@ func New(name string, storeType store.StoreType) *Worker {
 		s = store.NewInMemoryTaskStore()
 	}
 	w.Db = s
-	return &w
+	return &w, nil
 }
 
 // CollectStats used to periodically
@@ -58,74 +108,241 @@ func (w *Worker) CollectStats() {
 // This method is responsible for identifying
 // the task's current state and then either
 // starting or stopping a task based on the state.
-func (w *Worker) RunTask() task.DockerResult {
+func (w *Worker) RunTask() task.RuntimeResult {
 	t := w.Queue.Dequeue()
 	if t == nil {
 		log.Println("[worker.Worker] [RunTask] No tasks in the queue")
-		return task.DockerResult{Error: nil}
+		return task.RuntimeResult{Error: nil}
 	}
 	taskQueued := t.(task.Task)
-	err := w.Db.Put(taskQueued.ID.String(), &taskQueued)
+	taskPersisted, err := w.claimTask(taskQueued)
 	if err != nil {
-		msg := fmt.Errorf("error storing task %s: %v", taskQueued.ID.String(), err)
-		log.Printf("[worker.Worker] [RunTask] %v\n", msg)
-		return task.DockerResult{Error: msg}
+		log.Printf("[worker.Worker] [RunTask] %v\n", err)
+		return task.RuntimeResult{Error: err}
 	}
-	queuedTask, err := w.Db.Get(taskQueued.ID.String())
-	if err != nil {
-		msg := fmt.Errorf("error getting task %s from database^ %v", taskQueued.ID.String(), err)
-		log.Printf("[worker.Worker] [RunTask] %v\n", msg)
-		return task.DockerResult{Error: msg}
-	}
-	taskPersisted := queuedTask
-	var result task.DockerResult
-	if task.IsValidStateTransition(taskPersisted.State, taskQueued.State) {
-		switch taskQueued.State {
-		case task.Scheduled:
-			result = w.StartTask(taskQueued)
-		case task.Completed:
-			result = w.StopTask(taskQueued)
-		default:
-			result.Error = errors.New("invalid task state")
-		}
-	} else {
-		err := fmt.Errorf("invalid transition from %v to %v", taskPersisted.State, taskQueued.State)
-		result.Error = err
+	defer w.releaseTask(taskQueued.ID)
+	var result task.RuntimeResult
+	switch taskQueued.State {
+	case task.Scheduled:
+		if taskPersisted.ContainerID != "" {
+			// Task is being restarted, so its previous
+			// instance has to be removed first
+			w.stopInstance(taskPersisted)
+		}
+		result = w.StartTask(taskQueued)
+	case task.Completed:
+		// Sidecars are only tracked by the worker
+		taskQueued.SidecarIDs = taskPersisted.SidecarIDs
+		result = w.StopTask(taskQueued)
+	default:
+		result.Error = errors.New("invalid task state")
 	}
 	return result
 }
 
-func (w *Worker) StartTask(t task.Task) task.DockerResult {
+// claimTask stores the queued task if its state can change
+// to the queued one and marks it busy until releaseTask.
+// It returns the previously stored task.
+func (w *Worker) claimTask(t task.Task) (*task.Task, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	taskPersisted, err := w.Db.Get(t.ID.String())
+	if err != nil {
+		// Task is new to this worker
+		taskPersisted = &task.Task{ID: t.ID, State: task.Pending}
+	}
+	if !task.IsValidStateTransition(taskPersisted.State, t.State) {
+		return nil, fmt.Errorf("invalid transition from %v to %v", taskPersisted.State, t.State)
+	}
+	err = w.Db.Put(t.ID.String(), &t)
+	if err != nil {
+		return nil, fmt.Errorf("error storing task %s: %v", t.ID.String(), err)
+	}
+	w.busy[t.ID] = true
+	return taskPersisted, nil
+}
+
+func (w *Worker) releaseTask(id uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.busy, id)
+}
+
+// putTask stores task t while RunTask does not hold mu.
+func (w *Worker) putTask(t *task.Task) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.Db.Put(t.ID.String(), t)
+}
+
+func (w *Worker) StartTask(t task.Task) task.RuntimeResult {
 	t.StartTime = time.Now().UTC()
 	c := task.NewConfig(&t)
-	d := task.NewDocker(c)
-	result := d.Run()
+	c.Labels[task.LabelWorker] = w.Name
+	for _, s := range c.Sidecars {
+		s.Labels[task.LabelWorker] = w.Name
+	}
+	for _, ic := range c.InitContainers {
+		ic.Labels[task.LabelWorker] = w.Name
+	}
+	err := w.runInitContainers(&t, c)
+	if err != nil {
+		log.Printf("[worker.Worker] [StartTask] Error running init containers of task %v: %v\n", t.ID, err)
+		return w.failTask(t, err)
+	}
+	result := w.Runtime.Run(*c)
+	t.AddEvents(result.Events...)
 	if result.Error != nil {
 		log.Printf("[worker.Worker] [StartTask] Error running task %v: %v\n", t.ID, result.Error)
-		t.State = task.Failed
-		w.Db.Put(t.ID.String(), &t)
-		return result
+		return w.failTask(t, result.Error)
 	}
 	t.ContainerID = result.ContainerId
+	err = w.startSidecars(&t, c)
+	if err != nil {
+		log.Printf("[worker.Worker] [StartTask] Error running sidecars of task %v: %v\n", t.ID, err)
+		w.stopContainers(&t)
+		t.FinishTime = time.Now().UTC()
+		return w.failTask(t, err)
+	}
 	t.State = task.Running
-	w.Db.Put(t.ID.String(), &t)
+	t.Reason = ""
+	t.ExitCode = 0
+	if t.PostStart != nil {
+		t.HostPorts = w.Runtime.Inspect(t.ContainerID).HostPorts
+		err := w.runHook(&t, "PostStart", t.PostStart)
+		if err != nil && t.PostStart.FailTask {
+			w.stopContainers(&t)
+			t.FinishTime = time.Now().UTC()
+			return w.failTask(t, err)
+		}
+	}
+	w.putTask(&t)
 	return result
 }
 
-func (w *Worker) StopTask(t task.Task) task.DockerResult {
-	c := task.NewConfig(&t)
-	d := task.NewDocker(c)
-	result := d.Stop(t.ContainerID)
+// failTask marks a task that could not be started as failed.
+func (w *Worker) failTask(t task.Task, err error) task.RuntimeResult {
+	t.State = task.Failed
+	t.Reason = err.Error()
+	w.putTask(&t)
+	return task.RuntimeResult{Error: err, Events: t.Events}
+}
+
+func (w *Worker) StopTask(t task.Task) task.RuntimeResult {
+	result, hookErr := w.stopInstance(&t)
 	if result.Error != nil {
 		log.Printf("[worker.Worker] [StopTask] Error stopping container %v: %v\n", t.ContainerID, result.Error)
 	}
 	t.FinishTime = time.Now().UTC()
 	t.State = task.Completed
-	w.Db.Put(t.ID.String(), &t)
+	if hookErr != nil && t.PreStop.FailTask {
+		t.State = task.Failed
+		t.Reason = hookErr.Error()
+	}
+	w.putTask(&t)
 	log.Printf("[worker.Worker] [StopTask] Stopped and removed container %v for task %v\n", t.ContainerID, t.ID)
 	return result
 }
 
+// Validate checks that the worker is able to run task t.
+// Sources of bind mounts are replaced with their resolved
+// paths, so a symlink swapped in after validation does not
+// change what is mounted.
+func (w *Worker) Validate(t *task.Task) error {
+	mounts := [][]task.Mount{t.Mounts}
+	for _, s := range t.Sidecars {
+		mounts = append(mounts, s.Mounts)
+	}
+	for _, ic := range t.InitContainers {
+		mounts = append(mounts, ic.Mounts)
+	}
+	for _, ms := range mounts {
+		for i := range ms {
+			if ms[i].Type != task.BindMount {
+				continue
+			}
+			source, ok := w.resolveBind(ms[i].Source)
+			if !ok {
+				return fmt.Errorf("bind mount of %s is not allowed on worker %s", ms[i].Source, w.Name)
+			}
+			ms[i].Source = source
+		}
+	}
+	c := task.NewConfig(t)
+	for _, h := range []*task.Hook{t.PostStart, t.PreStop} {
+		if h == nil {
+			continue
+		}
+		err := h.Validate()
+		if err != nil {
+			return err
+		}
+		if _, ok := w.Runtime.(task.Executor); len(h.Exec) > 0 && !ok {
+			return errors.New("exec hooks are not supported by the worker's runtime")
+		}
+	}
+	err := t.ValidateRestartPolicy()
+	if err != nil {
+		return err
+	}
+	if t.StopGracePeriodSeconds < 0 {
+		return errors.New("stop grace period cannot be negative")
+	}
+	for _, ic := range t.InitContainers {
+		if ic.TimeoutSeconds < 0 {
+			return fmt.Errorf("init container %s: timeout cannot be negative", ic.Name)
+		}
+	}
+	for _, p := range []*task.Probe{t.HealthProbe, t.ReadinessProbe} {
+		if p == nil {
+			continue
+		}
+		err := p.Validate()
+		if err != nil {
+			return err
+		}
+		if _, ok := w.Runtime.(task.Executor); p.Exec != nil && !ok {
+			return errors.New("exec probes are not supported by the worker's runtime")
+		}
+	}
+	for _, ic := range c.InitContainers {
+		err := w.Runtime.Validate(ic)
+		if err != nil {
+			return fmt.Errorf("init container %s: %v", ic.Labels[task.LabelInitContainer], err)
+		}
+	}
+	return w.Runtime.Validate(*c)
+}
+
+// resolveBind resolves symlinks of the path and checks
+// whether it is inside one of AllowedBindPaths, so a
+// symlink cannot point out of an allowed directory.
+func (w *Worker) resolveBind(path string) (string, bool) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for _, allowed := range w.AllowedBindPaths {
+		allowed, err := filepath.EvalSymlinks(allowed)
+		if err != nil {
+			continue
+		}
+		allowed, err = filepath.Abs(allowed)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(allowed, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return path, true
+		}
+	}
+	return "", false
+}
+
 // AddTask adds a task to a temporary storage.
 func (w *Worker) AddTask(t task.Task) {
 	w.Queue.Enqueue(t)
@@ -155,10 +372,8 @@ func (w *Worker) RunTasks() {
 	}
 }
 
-func (w *Worker) InspectTask(t task.Task) task.DockerInspectResponse {
-	config := task.NewConfig(&t)
-	d := task.NewDocker(config)
-	return d.Inspect(t.ContainerID)
+func (w *Worker) InspectTask(t task.Task) task.InspectResponse {
+	return w.Runtime.Inspect(t.ContainerID)
 }
 
 func (w *Worker) UpdateTasks() {
@@ -172,29 +387,146 @@ func (w *Worker) UpdateTasks() {
 }
 
 func (w *Worker) updateTasks() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	tasks, err := w.Db.List()
 	if err != nil {
 		log.Printf("[worker.Worker] [updateTasks] Error getting list of tasks: %v\n", err)
 		return
 	}
 	for _, t := range tasks {
+		if w.busy[t.ID] {
+			continue
+		}
 		if t.State == task.Running {
 			resp := w.InspectTask(*t)
 			if resp.Error != nil {
-				log.Printf("[worker.Worker] [updateTasks] ERROR: %v\n", resp.Error)
-			}
-			if resp.Container == nil {
-				log.Printf("[worker.Worker] [updateTasks] No container for running task %s\n", t.ID.String())
+				log.Printf("[worker.Worker] [updateTasks] No container for running task %s: %v\n", t.ID.String(), resp.Error)
 				t.State = task.Failed
+				t.Reason = resp.Error.Error()
 				w.Db.Put(t.ID.String(), t)
+				continue
 			}
-			if resp.Container.State.Status == "exited" {
-				log.Printf("[worker.Worker] [updateTasks] Container for task %s in non-running state %s", t.ID.String(), resp.Container.State.Status)
+			if resp.Status == "exited" {
+				log.Printf("[worker.Worker] [updateTasks] Container for task %s in non-running state %s with exit code %d", t.ID.String(), resp.Status, resp.ExitCode)
+				// Sidecars do not outlive the main container,
+				// e.g. when a job has run to completion
+				w.stopSidecars(t)
+				t.FinishTime = time.Now().UTC()
+				t.ExitCode = resp.ExitCode
+				// The exit code only tells how the instance has
+				// finished, the manager decides what it means for
+				// the kind of the task
+				if resp.ExitCode == 0 {
+					t.State = task.Completed
+				} else {
+					t.State = task.Failed
+				}
+				w.Db.Put(t.ID.String(), t)
+				continue
+			}
+			if err := w.failedSidecar(t); err != nil {
+				log.Printf("[worker.Worker] [updateTasks] Task %s has failed: %v\n", t.ID.String(), err)
+				t.AddEvents(task.NewEvent("SidecarFailed", err.Error()))
+				w.stopContainers(t)
+				t.FinishTime = time.Now().UTC()
 				t.State = task.Failed
+				t.Reason = err.Error()
 				w.Db.Put(t.ID.String(), t)
+				continue
 			}
-			t.HostPorts = resp.Container.NetworkSettings.NetworkSettingsBase.Ports
+			t.HostPorts = resp.HostPorts
 			w.Db.Put(t.ID.String(), t)
 		}
 	}
 }
+
+// Reconcile periodically matches instances
+// started by the worker's runtime against tasks in Db.
+func (w *Worker) Reconcile() {
+	for {
+		log.Println("[worker.Worker] [Reconcile] Reconciling task instances")
+		w.reconcile()
+		log.Println("[worker.Worker] [Reconcile] Sleeping for 60 seconds")
+		time.Sleep(60 * time.Second)
+	}
+}
+
+// reconcile adopts instances that belong to tasks in Db
+// but are not tracked by them (e.g. the worker has crashed
+// before storing ContainerID) and handles instances of
+// unknown or finished tasks according to OrphanPolicy.
+func (w *Worker) reconcile() {
+	lister, ok := w.Runtime.(task.Lister)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	instances, err := lister.List()
+	if err != nil {
+		log.Printf("[worker.Worker] [reconcile] Error listing instances: %v\n", err)
+		return
+	}
+	for _, inst := range instances {
+		if inst.Labels[task.LabelWorker] != w.Name {
+			continue
+		}
+		t, err := w.Db.Get(inst.Labels[task.LabelTaskID])
+		if err == nil && (t.ContainerID == inst.ID || w.busy[t.ID]) {
+			continue
+		}
+		// Sidecars belong to the main instance of
+		// their task group and are never adopted
+		if main, ok := inst.Labels[task.LabelSidecarOf]; ok {
+			if err != nil || t.ContainerID != main {
+				w.handleOrphan(inst)
+			}
+			continue
+		}
+		// Init containers only exist while
+		// their task is being started
+		if _, ok := inst.Labels[task.LabelInitContainer]; ok {
+			if err != nil || t.State != task.Scheduled {
+				w.handleOrphan(inst)
+			}
+			continue
+		}
+		if err == nil && w.adopt(t, inst) {
+			continue
+		}
+		w.handleOrphan(inst)
+	}
+}
+
+// adopt makes the instance the tracked instance of task t
+// if the task is supposed to be running and its current
+// instance is missing.
+func (w *Worker) adopt(t *task.Task, inst task.Instance) bool {
+	if t.State != task.Scheduled && t.State != task.Running {
+		return false
+	}
+	if t.ContainerID != "" && w.Runtime.Inspect(t.ContainerID).Error == nil {
+		return false
+	}
+	log.Printf("[worker.Worker] [adopt] Adopting instance %s of task %s\n", inst.ID, t.ID)
+	t.ContainerID = inst.ID
+	if inst.Status == "running" {
+		t.State = task.Running
+	}
+	w.Db.Put(t.ID.String(), t)
+	return true
+}
+
+func (w *Worker) handleOrphan(inst task.Instance) {
+	switch w.OrphanPolicy {
+	case OrphanRemove:
+		log.Printf("[worker.Worker] [handleOrphan] Removing orphaned instance %s of task %s\n", inst.ID, inst.Labels[task.LabelTaskID])
+		result := w.Runtime.Stop(inst.ID)
+		if result.Error != nil {
+			log.Printf("[worker.Worker] [handleOrphan] Error removing instance %s: %v\n", inst.ID, result.Error)
+		}
+	default:
+		log.Printf("[worker.Worker] [handleOrphan] Found orphaned instance %s of task %s\n", inst.ID, inst.Labels[task.LabelTaskID])
+	}
+}
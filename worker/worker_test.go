@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vasilii314/orchestrator/store"
+	"github.com/vasilii314/orchestrator/task"
+)
+
+func TestNewRuntime(t *testing.T) {
+	tests := []struct {
+		runtime task.RuntimeType
+		wantErr bool
+	}{
+		{task.ProcessRuntime, false},
+		{task.DockerRuntime, false},
+		{"", true},
+		{"podman", true},
+	}
+	for _, tt := range tests {
+		w, err := New("test", store.InMemoryStore, tt.runtime)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("runtime %q: error = %v, want error %v", tt.runtime, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		switch tt.runtime {
+		case task.ProcessRuntime:
+			if _, ok := w.Runtime.(*task.Process); !ok {
+				t.Errorf("runtime %q: got %T", tt.runtime, w.Runtime)
+			}
+		case task.DockerRuntime:
+			if _, ok := w.Runtime.(*task.Docker); !ok {
+				t.Errorf("runtime %q: got %T", tt.runtime, w.Runtime)
+			}
+		}
+	}
+}
+
+func TestValidateBindMounts(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	os.Mkdir(filepath.Join(allowed, "data"), 0700)
+	os.Symlink(filepath.Join(allowed, "data"), filepath.Join(allowed, "link"))
+	os.Symlink(outside, filepath.Join(allowed, "escape"))
+	w, err := New("test", store.InMemoryStore, task.DockerRuntime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.AllowedBindPaths = []string{allowed}
+	tests := []struct {
+		source string
+		// want is the mounted source, empty
+		// if the mount is not allowed
+		want string
+	}{
+		{filepath.Join(allowed, "data"), filepath.Join(allowed, "data")},
+		{filepath.Join(allowed, "link"), filepath.Join(allowed, "data")},
+		{filepath.Join(allowed, "escape"), ""},
+		{outside, ""},
+		{filepath.Join(allowed, "missing"), ""},
+	}
+	for _, tt := range tests {
+		tk := task.Task{
+			Image:  "alpine",
+			Mounts: []task.Mount{{Type: task.BindMount, Source: tt.source, Target: "/data"}},
+		}
+		err := w.Validate(&tk)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: bind mount is allowed", tt.source)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.source, err)
+		case tt.want != "" && tk.Mounts[0].Source != tt.want:
+			t.Errorf("%s: source = %s, want %s", tt.source, tk.Mounts[0].Source, tt.want)
+		}
+	}
+}
+
+func TestPreStopRunsOnlyForRunningInstances(t *testing.T) {
+	tests := []struct {
+		cmd      []string
+		wantHook bool
+	}{
+		{[]string{"sleep", "30"}, true},
+		{[]string{"true"}, false},
+	}
+	for _, tt := range tests {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		}))
+		u, _ := url.Parse(srv.URL)
+		w, err := New("test", store.InMemoryStore, task.ProcessRuntime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Runtime = task.NewProcess(t.TempDir())
+		tk := task.Task{
+			ID:      uuid.New(),
+			State:   task.Scheduled,
+			Cmd:     tt.cmd,
+			PreStop: &task.Hook{HTTPGet: &task.HTTPGetAction{Path: "/", Port: u.Port()}},
+		}
+		w.AddTask(tk)
+		result := w.RunTask()
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		if len(w.busy) != 0 {
+			t.Errorf("%v: task is still busy after it has started", tt.cmd)
+		}
+		started, _ := w.Db.Get(tk.ID.String())
+		want := "running"
+		if !tt.wantHook {
+			want = "exited"
+		}
+		for i := 0; i < 50 && w.Runtime.Inspect(started.ContainerID).Status != want; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		stop := *started
+		stop.State = task.Completed
+		w.AddTask(stop)
+		w.RunTask()
+		if got := calls.Load() > 0; got != tt.wantHook {
+			t.Errorf("%v: PreStop hook called %v, want %v", tt.cmd, got, tt.wantHook)
+		}
+		srv.Close()
+	}
+}